@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+// Keys of the runtime settings stored by /beer-admin; they override the
+// environment (READ_ONLY, MAX_BEER_GIFT, MAX_PER_DAY) until changed again.
+const (
+	settingReadOnly  = "read_only"
+	settingMaxGift   = "max_gift"
+	settingMaxPerDay = "max_per_day"
+)
+
+// adminGroupTTL bounds how long the members of ADMIN_USERGROUP are reused.
+const adminGroupTTL = 5 * time.Minute
+
+// settingsTTL bounds how long settings and channel rules changed with /beer-admin
+// on another replica take to apply on this one.
+const settingsTTL = 30 * time.Second
+
+var (
+	userIDPattern      = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+	messageLinkPattern = regexp.MustCompile(`/p(\d{10})(\d{6})`)
+)
+
+// isAdmin reports whether the user may run /beer-admin: listed in ADMIN_USERS or
+// a member of the ADMIN_USERGROUP user group.
+func (bot *MinimalSlackBot) isAdmin(user string) bool {
+	if bot.admins[user] {
+		return true
+	}
+	if bot.adminGroup == "" || bot.api == nil {
+		return false
+	}
+	bot.adminMu.Lock()
+	defer bot.adminMu.Unlock()
+	if bot.groupMembers == nil || time.Since(bot.groupFetched) > adminGroupTTL {
+		members, err := bot.api.GetUserGroupMembers(bot.adminGroup)
+		if err != nil {
+			bot.logger.Warn().Err(err).Str("usergroup", bot.adminGroup).Msg("Failed to look up admin user group")
+			return bot.groupMembers[user] // keep the last known members
+		}
+		bot.groupMembers = parseIDList(strings.Join(members, ","))
+		bot.groupFetched = time.Now()
+	}
+	return bot.groupMembers[user]
+}
+
+// loadSettings applies the runtime settings stored by /beer-admin.
+func (bot *MinimalSlackBot) loadSettings(ctx context.Context) error {
+	settings, err := bot.store.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if v, ok := settings[settingReadOnly]; ok {
+		bot.readOnly = v == "true"
+	}
+	if n, err := strconv.Atoi(settings[settingMaxGift]); err == nil && n > 0 {
+		bot.maxGift = n
+	}
+	if n, err := strconv.Atoi(settings[settingMaxPerDay]); err == nil && n >= 0 {
+		bot.maxPerDay = n
+	}
+	return nil
+}
+
+// refreshSettings reloads the /beer-admin settings and channel rules once they are
+// older than settingsTTL, so every replica follows changes made on any of them. A
+// failed reload keeps the current values and is retried with the next event.
+func (bot *MinimalSlackBot) refreshSettings(ctx context.Context) {
+	if bot.store == nil || time.Since(bot.settingsLoaded) < settingsTTL {
+		return
+	}
+	if err := bot.loadSettings(ctx); err != nil {
+		bot.logger.Error().Err(err).Msg("Failed to reload settings")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	if bot.channels != nil {
+		if err := bot.channels.load(ctx, bot.store); err != nil {
+			bot.logger.Error().Err(err).Msg("Failed to reload channel rules")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			return
+		}
+	}
+	bot.settingsLoaded = time.Now()
+}
+
+// handleBeerAdmin dispatches /beer-admin subcommands.
+func (bot *MinimalSlackBot) handleBeerAdmin(ctx context.Context, cmd slack.SlashCommand) {
+	if !bot.isAdmin(cmd.UserID) {
+		bot.postEphemeral(cmd.ChannelID, cmd.UserID, "⛔ /beer-admin is restricted to BeerBot admins.")
+		return
+	}
+	bot.postEphemeral(cmd.ChannelID, cmd.UserID, bot.adminCommand(ctx, cmd.UserID, strings.Fields(cmd.Text)))
+}
+
+// adminCommand runs a /beer-admin subcommand for an admin and returns the reply text.
+func (bot *MinimalSlackBot) adminCommand(ctx context.Context, admin string, args []string) string {
+	if len(args) == 0 {
+		return adminUsage
+	}
+	switch strings.ToLower(args[0]) {
+	case "channels":
+		reply := bot.adminChannels(ctx, args[1:])
+		if len(args) > 1 && strings.HasPrefix(reply, "✅") {
+			bot.auditAdmin(ctx, admin, "channels", strings.Join(args[1:], " "))
+		}
+		return reply
+	case "readonly":
+		return bot.adminReadOnly(ctx, admin, args[1:])
+	case "limits":
+		return bot.adminLimits(ctx, admin, args[1:])
+	case "revoke":
+		return bot.adminRevoke(ctx, admin, args[1:])
+	case "grant":
+		return bot.adminGrant(ctx, admin, args[1:])
+	case "audit":
+		return bot.adminAudit(ctx, args[1:])
+	}
+	return adminUsage
+}
+
+const adminUsage = "Usage:\n" +
+	"• `/beer-admin channels` — show the channel allow and deny lists\n" +
+	"• `/beer-admin channels allow|deny <#channel|type>` — add an entry (types: public, private, im, mpim)\n" +
+	"• `/beer-admin channels remove allow|deny <#channel|type>` — remove an entry\n" +
+	"• `/beer-admin readonly [on|off]` — show or toggle read-only mode\n" +
+	"• `/beer-admin limits [gift=N] [day=N]` — show or set the per-message and daily limits (day=0: no daily limit)\n" +
+	"• `/beer-admin revoke @giver|grant <message link|ts>` — revoke a gift or a grant\n" +
+	"• `/beer-admin grant @user N [reason]` — give corrective beers (they count for no giver)\n" +
+	"• `/beer-admin audit [N]` — show the latest admin actions"
+
+// adminChannels implements /beer-admin channels and returns the reply text.
+func (bot *MinimalSlackBot) adminChannels(ctx context.Context, args []string) string {
+	if bot.channels == nil {
+		return "Channel lists are not available."
+	}
+	if len(args) == 0 || strings.EqualFold(args[0], "list") {
+		return bot.channels.describe()
+	}
+
+	switch action := strings.ToLower(args[0]); action {
+	case "allow", "deny":
+		if len(args) != 2 {
+			return adminUsage
+		}
+		entry, err := normalizeChannelEntry(args[1])
+		if err != nil {
+			return "⚠️ " + err.Error()
+		}
+		if err := bot.store.AddChannelRule(ctx, action, entry); err != nil {
+			bot.logger.Error().Err(err).Str("list", action).Str("entry", entry).Msg("Failed to store channel rule")
+			return "⚠️ Failed to update channel lists."
+		}
+		if err := bot.channels.load(ctx, bot.store); err != nil {
+			bot.logger.Error().Err(err).Msg("Failed to reload channel rules")
+		}
+		return fmt.Sprintf("✅ Added %s to the %s list.\n%s", formatChannelEntry(entry), action, bot.channels.describe())
+	case "remove":
+		if len(args) != 3 || (args[1] != "allow" && args[1] != "deny") {
+			return adminUsage
+		}
+		list := args[1]
+		entry, err := normalizeChannelEntry(args[2])
+		if err != nil {
+			return "⚠️ " + err.Error()
+		}
+		if bot.channels.isEnvEntry(list, entry) {
+			return fmt.Sprintf("⚠️ %s is configured through the environment and cannot be removed at runtime.", formatChannelEntry(entry))
+		}
+		removed, err := bot.store.RemoveChannelRule(ctx, list, entry)
+		if err != nil {
+			bot.logger.Error().Err(err).Str("list", list).Str("entry", entry).Msg("Failed to remove channel rule")
+			return "⚠️ Failed to update channel lists."
+		}
+		if !removed {
+			return fmt.Sprintf("%s is not on the %s list.", formatChannelEntry(entry), list)
+		}
+		if err := bot.channels.load(ctx, bot.store); err != nil {
+			bot.logger.Error().Err(err).Msg("Failed to reload channel rules")
+		}
+		return fmt.Sprintf("✅ Removed %s from the %s list.\n%s", formatChannelEntry(entry), list, bot.channels.describe())
+	}
+	return adminUsage
+}
+
+// auditAdmin appends an action to the admin audit trail.
+func (bot *MinimalSlackBot) auditAdmin(ctx context.Context, admin, action, detail string) {
+	if err := bot.store.RecordAdminAction(ctx, admin, action, detail, time.Now()); err != nil {
+		bot.logger.Error().Err(err).Str("admin", admin).Str("action", action).Msg("Failed to record admin action")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+	}
+	bot.logger.Info().Str("admin", admin).Str("action", action).Str("detail", detail).Msg("Admin action")
+}
+
+// adminReadOnly implements /beer-admin readonly.
+func (bot *MinimalSlackBot) adminReadOnly(ctx context.Context, admin string, args []string) string {
+	if len(args) == 0 {
+		return fmt.Sprintf("Read-only mode is %s.", onOff(bot.readOnly))
+	}
+	var on bool
+	switch strings.ToLower(args[0]) {
+	case "on", "true":
+		on = true
+	case "off", "false":
+	default:
+		return adminUsage
+	}
+	if err := bot.store.SetSetting(ctx, settingReadOnly, strconv.FormatBool(on)); err != nil {
+		bot.logger.Error().Err(err).Msg("Failed to store read-only setting")
+		return "⚠️ Failed to update read-only mode."
+	}
+	bot.readOnly = on
+	bot.auditAdmin(ctx, admin, "readonly", onOff(on))
+	return fmt.Sprintf("✅ Read-only mode is now %s.", onOff(on))
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// adminLimits implements /beer-admin limits.
+func (bot *MinimalSlackBot) adminLimits(ctx context.Context, admin string, args []string) string {
+	if len(args) == 0 {
+		return bot.describeLimits()
+	}
+	gift, day := bot.maxGift, bot.maxPerDay
+	for _, a := range args {
+		k, v, ok := strings.Cut(a, "=")
+		n, err := strconv.Atoi(v)
+		if !ok || err != nil {
+			return adminUsage
+		}
+		switch strings.ToLower(k) {
+		case "gift":
+			if n < 1 {
+				return "⚠️ The per-message limit must be at least 1."
+			}
+			gift = n
+		case "day":
+			if n < 0 {
+				return "⚠️ The daily limit cannot be negative."
+			}
+			day = n
+		default:
+			return adminUsage
+		}
+	}
+	for key, n := range map[string]int{settingMaxGift: gift, settingMaxPerDay: day} {
+		if err := bot.store.SetSetting(ctx, key, strconv.Itoa(n)); err != nil {
+			bot.logger.Error().Err(err).Str("key", key).Msg("Failed to store limit")
+			return "⚠️ Failed to update limits."
+		}
+	}
+	bot.maxGift, bot.maxPerDay = gift, day
+	bot.auditAdmin(ctx, admin, "limits", fmt.Sprintf("gift=%d day=%d", gift, day))
+	return "✅ " + bot.describeLimits()
+}
+
+func (bot *MinimalSlackBot) describeLimits() string {
+	day := "no daily limit"
+	if bot.maxPerDay > 0 {
+		day = beerCount(bot.maxPerDay) + " per day"
+	}
+	return fmt.Sprintf("Limits: %s per message, %s.", beerCount(bot.maxGift), day)
+}
+
+// parseUserArg accepts a user mention (<@U123|name>) or a plain user ID.
+func parseUserArg(arg string) (string, bool) {
+	if m := mentionTokenPattern.FindStringSubmatch(arg); m != nil {
+		return m[1], true
+	}
+	if id := strings.ToUpper(arg); userIDPattern.MatchString(id) {
+		return id, true
+	}
+	return "", false
+}
+
+// parseGiftRef accepts a Slack message link (<https://…/archives/C1/p1717691574000100>)
+// or a message ts.
+func parseGiftRef(arg string) (string, bool) {
+	if m := messageLinkPattern.FindStringSubmatch(arg); m != nil {
+		return m[1] + "." + m[2], true
+	}
+	if _, err := strconv.ParseFloat(arg, 64); err == nil && strings.Contains(arg, ".") {
+		return arg, true
+	}
+	return "", false
+}
+
+// adminRevoke implements /beer-admin revoke: it revokes every beer of a gift and
+// strikes through its confirmation, as an undo does.
+func (bot *MinimalSlackBot) adminRevoke(ctx context.Context, admin string, args []string) string {
+	if len(args) != 2 {
+		return adminUsage
+	}
+	giver := storage.GrantGiver
+	if !strings.EqualFold(args[0], "grant") {
+		var ok bool
+		if giver, ok = parseUserArg(args[0]); !ok {
+			return "⚠️ Name the giver as @user, or `grant` for a grant."
+		}
+	}
+	ts, ok := parseGiftRef(args[1])
+	if !ok {
+		return "⚠️ Pass the gift's message link or ts."
+	}
+	if bot.readOnly {
+		return "Read-only mode: nothing was changed."
+	}
+	counts, err := bot.store.GetBeersByTS(ctx, giver, ts)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", giver).Str("ts", ts).Msg("Failed to load gift to revoke")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return "⚠️ Could not load the gift."
+	}
+	if len(counts) == 0 {
+		if giver == storage.GrantGiver {
+			return fmt.Sprintf("🤷 There is no active grant at %s.", ts)
+		}
+		return fmt.Sprintf("🤷 <@%s> has no active gift at %s.", giver, ts)
+	}
+
+	ref, err := bot.store.GiftAt(ctx, giver, ts)
+	if err != nil {
+		bot.logger.Warn().Err(err).Str("giver", giver).Str("ts", ts).Msg("Failed to look up gift confirmation")
+	}
+
+	recipients := make([]string, 0, len(counts))
+	for r := range counts {
+		recipients = append(recipients, r)
+	}
+	sort.Strings(recipients)
+	now := time.Now()
+	eventID := "admin_revoke:" + giver + ":" + ts
+	var revoked []recipientGift
+	for _, recipient := range recipients {
+		if err := bot.store.RevokeBeer(ctx, giver, recipient, ts, now); err != nil {
+			bot.logger.Error().Err(err).Str("giver", giver).Str("recipient", recipient).Str("ts", ts).Msg("Failed to revoke beer")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			continue
+		}
+		_ = bot.store.RecordBeerEventOutcome(ctx, eventID, giver, recipient, counts[recipient], "revoked", now)
+		bot.eventCounter.WithLabelValues("beer_giving", "revoked").Inc()
+		revoked = append(revoked, recipientGift{recipient: recipient, quantity: counts[recipient]})
+	}
+	if len(revoked) == 0 {
+		return "⚠️ Could not revoke the gift."
+	}
+	bot.auditAdmin(ctx, admin, "revoke", fmt.Sprintf("giver=%s ts=%s %s", giver, ts, formatGiftList(revoked)))
+	if ref != nil {
+		bot.strikeConfirmation(ref.ConfirmChannel, ref.ConfirmTS, formatBeerConfirmation(giver, revoked), "🚫 Revoked by an admin")
+	}
+	if giver == storage.GrantGiver {
+		bot.refreshHomes(ctx, recipients...)
+		return fmt.Sprintf("✅ Revoked the grant: %s.", formatGiftList(revoked))
+	}
+	bot.refreshHomes(ctx, append([]string{giver}, recipients...)...)
+	return fmt.Sprintf("✅ Revoked <@%s>'s gift: %s.", giver, formatGiftList(revoked))
+}
+
+// adminGrant implements /beer-admin grant: corrective beers recorded under
+// storage.GrantGiver, so they count for the recipient only. Grants bypass the
+// per-message limit; the audit trail keeps who granted them.
+func (bot *MinimalSlackBot) adminGrant(ctx context.Context, admin string, args []string) string {
+	if len(args) < 2 {
+		return adminUsage
+	}
+	recipient, ok := parseUserArg(args[0])
+	if !ok {
+		return "⚠️ Name the recipient as @user."
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || n > maxGiveQuantity {
+		return fmt.Sprintf("⚠️ Grant between 1 and %d beers.", maxGiveQuantity)
+	}
+	if recipient == admin {
+		return "🚫 You can't grant beers to yourself."
+	}
+	if bot.readOnly {
+		return "Read-only mode: nothing was changed."
+	}
+	reason := strings.Join(args[2:], " ")
+	if reason == "" {
+		reason = "correction"
+	}
+	now := time.Now().In(bot.userLocation(admin))
+	ts := formatSlackTS(now)
+	if err := bot.store.AddBeer(ctx, storage.GrantGiver, recipient, ts, now, n, storage.BeerNote{Reason: reason}); err != nil {
+		bot.logger.Error().Err(err).Str("recipient", recipient).Int("quantity", n).Msg("Failed to grant beers")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return "⚠️ Could not grant the beers."
+	}
+	_ = bot.store.RecordBeerEventOutcome(ctx, "admin_grant:"+ts, storage.GrantGiver, recipient, n, "granted", now)
+	bot.eventCounter.WithLabelValues("beer_giving", "granted").Inc()
+	bot.auditAdmin(ctx, admin, "grant", fmt.Sprintf("recipient=%s count=%d ts=%s reason=%s", recipient, n, ts, reason))
+	bot.refreshHomes(ctx, recipient)
+	return fmt.Sprintf("✅ Granted %s to <@%s> (%s). Undo with `/beer-admin revoke grant %s`.", beerCount(n), recipient, reason, ts)
+}
+
+// adminAudit implements /beer-admin audit.
+func (bot *MinimalSlackBot) adminAudit(ctx context.Context, args []string) string {
+	limit := 10
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil && n > 0 && n <= 50 {
+			limit = n
+		}
+	}
+	actions, err := bot.store.AdminActions(ctx, limit)
+	if err != nil {
+		bot.logger.Error().Err(err).Msg("Failed to load admin audit")
+		return "⚠️ Could not load the admin audit trail."
+	}
+	if len(actions) == 0 {
+		return "No admin actions yet."
+	}
+	var b strings.Builder
+	b.WriteString("*Latest admin actions*")
+	for _, a := range actions {
+		fmt.Fprintf(&b, "\n• %s <@%s> %s %s", a.Time.In(bot.workspaceLocation()).Format("Jan 2 15:04"), a.Admin, a.Action, a.Detail)
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+	_ "modernc.org/sqlite"
+)
+
+func TestAdminCommands(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "admin.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := storage.NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	bot := newTestBot(t, s, func(b *MinimalSlackBot) { b.maxPerDay = 10 })
+
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"limits", "gift=3", "day=0"}); !strings.Contains(reply, "no daily limit") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"readonly", "on"}); !strings.Contains(reply, "now on") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"grant", "<@UA|anna>", "2"}); !strings.HasPrefix(reply, "Read-only") {
+		t.Fatalf("grants must respect read-only mode, got %s", reply)
+	}
+
+	// settings survive a restart and override the environment
+	restarted := newTestBot(t, s, func(b *MinimalSlackBot) { b.maxPerDay = 10 })
+	if err := restarted.loadSettings(ctx); err != nil {
+		t.Fatalf("load settings: %v", err)
+	}
+	if !restarted.readOnly || restarted.maxGift != 3 || restarted.maxPerDay != 0 {
+		t.Fatalf("settings not applied: readOnly=%v maxGift=%d maxPerDay=%d", restarted.readOnly, restarted.maxGift, restarted.maxPerDay)
+	}
+
+	// another replica picks the changes up once its copy is older than settingsTTL
+	replica := newTestBot(t, s, func(b *MinimalSlackBot) {
+		b.maxPerDay = 10
+		b.channels, _ = newChannelPolicy("", "")
+		b.settingsLoaded = time.Now()
+	})
+	if err := s.AddChannelRule(ctx, "deny", "C9"); err != nil {
+		t.Fatalf("add channel rule: %v", err)
+	}
+	replica.refreshSettings(ctx)
+	if replica.readOnly || !replica.channels.allows("C9", "") {
+		t.Fatalf("expected a fresh copy to be kept: readOnly=%v", replica.readOnly)
+	}
+	replica.settingsLoaded = time.Now().Add(-settingsTTL)
+	replica.refreshSettings(ctx)
+	if !replica.readOnly || replica.maxGift != 3 || replica.maxPerDay != 0 || replica.channels.allows("C9", "") {
+		t.Fatalf("settings not reloaded: readOnly=%v maxGift=%d maxPerDay=%d", replica.readOnly, replica.maxGift, replica.maxPerDay)
+	}
+
+	bot.adminCommand(ctx, "UADMIN", []string{"readonly", "off"})
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"grant", "<@UA|anna>", "2", "missed", "gift"}); !strings.Contains(reply, "Granted 2 beers") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	recent, _ := s.RecentReceived(ctx, "UA", 1)
+	if len(recent) != 1 || recent[0].Giver != storage.GrantGiver || recent[0].Reason != "missed gift" {
+		t.Fatalf("unexpected granted gift %+v", recent)
+	}
+	day := recent[0].Time.Format("2006-01-02")
+	if given, _ := s.CountGivenOnDate(ctx, "UADMIN", day); given != 0 {
+		t.Fatalf("grant used up %d of the admin's budget", given)
+	}
+	if ref, _ := s.LatestGift(ctx, "UADMIN"); ref != nil {
+		t.Fatalf("grant is undoable by the admin: %+v", ref)
+	}
+	if givers, _ := s.TopGivers(ctx, recent[0].Time, recent[0].Time, 5); len(givers) != 0 {
+		t.Fatalf("grant counted as a giver: %v", givers)
+	}
+
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"revoke", "<@UADMIN>", recent[0].TS}); !strings.Contains(reply, "no active gift") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	link := "<https://example.slack.com/archives/C1/p" + strings.Replace(recent[0].TS, ".", "", 1) + ">"
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"revoke", "grant", link}); !strings.Contains(reply, "Revoked the grant") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	if given, received, _ := s.UserTotals(ctx, "UA", recent[0].Time.AddDate(0, 0, -1), recent[0].Time.AddDate(0, 0, 1)); given != 0 || received != 0 {
+		t.Fatalf("revoked gift still counted: %d/%d", given, received)
+	}
+
+	actions, err := s.AdminActions(ctx, 10)
+	if err != nil {
+		t.Fatalf("admin actions: %v", err)
+	}
+	var got []string
+	for _, a := range actions {
+		got = append(got, a.Action)
+	}
+	if strings.Join(got, ",") != "revoke,grant,readonly,readonly,limits" {
+		t.Fatalf("unexpected audit trail %v", got)
+	}
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"audit", "1"}); !strings.Contains(reply, "revoke") {
+		t.Fatalf("unexpected audit reply: %s", reply)
+	}
+}
+
+func TestAdminRevoke_StrikesConfirmation(t *testing.T) {
+	ctx := t.Context()
+	var updates []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/chat.update") {
+			_ = r.ParseForm()
+			updates = append(updates, r.FormValue("channel")+" "+r.FormValue("ts")+": "+r.FormValue("text"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"9.9"}`))
+	}))
+	defer srv.Close()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.api = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/")) })
+
+	_ = ms.AddBeer(ctx, "UG", "UA", "1.1", time.Now(), 2, storage.BeerNote{})
+	_ = ms.SetConfirmation(ctx, "UG", "1.1", "C1", "9.9")
+	if reply := bot.adminCommand(ctx, "UADMIN", []string{"revoke", "<@UG>", "1.1"}); !strings.Contains(reply, "Revoked <@UG>'s gift") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	want := "C1 9.9: ~🍻 <@UG> gave 2 beers to <@UA>!~\n🚫 Revoked by an admin"
+	if len(updates) != 1 || updates[0] != want {
+		t.Fatalf("expected the confirmation struck through, got %q", updates)
+	}
+}
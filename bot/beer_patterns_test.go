@@ -22,7 +22,7 @@ func TestBroadBeerPatterns(t *testing.T) {
 		"<@U12345> gives beer",                      // mention then verb then keyword
 	}
 	for _, c := range cases {
-		if !bot.isBeerGiving(c) {
+		if bot.parseGift(c) == nil {
 			// Fail fast with example of missed pattern
 			// (pattern set should catch all above)
 			// Keep output short for clarity.
@@ -43,9 +43,106 @@ func TestNegativeBeerPatterns(t *testing.T) {
 		"Give everyone applause <@U12345>",    // give verb but no beer content
 	}
 	for _, c := range negatives {
-		if bot.isBeerGiving(c) {
+		if bot.parseGift(c) != nil {
 			// It's okay to log which pattern misfired if needed later; for now just fail.
 			t.Fatalf("unexpected beer gift detection for: %q", c)
 		}
 	}
 }
+
+// TestCustomGiftVocabulary ensures configured emoji, weights and verbs drive intent and quantity.
+func TestCustomGiftVocabulary(t *testing.T) {
+	vocab, err := newGiftVocabulary("🍺,:beer-mug:,:prost:=2", "bier", "gebe,give")
+	if err != nil {
+		t.Fatalf("new vocabulary: %v", err)
+	}
+	bot := &MinimalSlackBot{vocab: vocab}
+	for _, c := range []string{
+		":beer-mug: <@U12345>",
+		"<@U12345> :prost:",
+		"ich gebe <@U12345> 2 bier",
+		"Bier <@U12345>",
+	} {
+		if bot.parseGift(c) == nil {
+			t.Fatalf("expected beer gift intent detected for: %q", c)
+		}
+	}
+	for _, c := range []string{
+		":beers: <@U12345>", // not part of the configured vocabulary
+		"beer <@U12345>",
+	} {
+		if bot.parseGift(c) != nil {
+			t.Fatalf("unexpected beer gift detection for: %q", c)
+		}
+	}
+	if gift := bot.parseGift("<@U12345> :prost: :beer-mug:"); gift == nil || gift.Recipients[0].quantity != 3 {
+		t.Fatalf("expected weighted quantity 3 got %+v", gift)
+	}
+
+	if _, err := newGiftVocabulary(":prost:=zero", "", ""); err == nil {
+		t.Fatalf("expected error for invalid weight")
+	}
+}
+
+// TestParseGiftCorpus runs the gift parser over a corpus of real-world message shapes.
+func TestParseGiftCorpus(t *testing.T) {
+	bot := &MinimalSlackBot{}
+	type want struct {
+		recipients map[string]int // nil: not a gift
+		order      []string
+		reason     string
+	}
+	cases := []struct {
+		text string
+		want want
+	}{
+		{"<@UA> 🍺", want{map[string]int{"UA": 1}, []string{"UA"}, ""}},
+		{"🍺🍺 <@UA> <@UB>", want{map[string]int{"UA": 2, "UB": 2}, []string{"UA", "UB"}, ""}},
+		{"<@UA> 🍺🍺 <@UB> 🍺", want{map[string]int{"UA": 2, "UB": 1}, []string{"UA", "UB"}, ""}},
+		{"🍺 <@UA> 🍺🍺 <@UB>", want{map[string]int{"UA": 1, "UB": 2}, []string{"UA", "UB"}, ""}},
+		{"🍺 <@UA> 🍺🍺", want{map[string]int{"UA": 3}, []string{"UA"}, ""}},
+		{"<@UA>, <@UB> and <@UC> 🍻", want{map[string]int{"UA": 1, "UB": 1, "UC": 1}, []string{"UA", "UB", "UC"}, ""}},
+		{"give <@UA> 3 beers for the review", want{map[string]int{"UA": 3}, []string{"UA"}, "for the review"}},
+		{"<@UA> gives 4 🍺🍺🍺🍺", want{map[string]int{"UA": 4}, []string{"UA"}, ""}},
+		{"I give 5 beers to <@UA>", want{map[string]int{"UA": 5}, []string{"UA"}, ""}},
+		{"thanks all! <@UA> 🍺 for the demo", want{map[string]int{"UA": 1}, []string{"UA"}, "for the demo"}},
+		{"<@UA> 🍺 for fixing 3 bugs in prod", want{map[string]int{"UA": 1}, []string{"UA"}, "for fixing 3 bugs in prod"}},
+		{"<@UA|alice> :beer: — thanks for the help, <@UB>!", want{map[string]int{"UA": 1}, []string{"UA"}, "thanks for the help, <@UB>!"}},
+		{"<@UA> 🍺 for `make 5 builds` see <https://ci.example.com/10|build 10>", want{map[string]int{"UA": 1}, []string{"UA"}, "for `make 5 builds` see <https://ci.example.com/10|build 10>"}},
+		{"&gt; 🍺 <@UA>\nquoting the above", want{}},
+		{"```\n🍺 <@UA>\n```", want{}},
+		{"`🍺 <@UA>`", want{}},
+		{"<https://example.com/🍺|🍺> <@UA>", want{}},
+		{"&gt; earlier message\n<@UA> 2 🍺", want{map[string]int{"UA": 2}, []string{"UA"}, ""}},
+		{"&gt; can someone review?\n<@UA> 🍺 for the review", want{map[string]int{"UA": 1}, []string{"UA"}, "for the review"}},
+		{"<@UA> 🍺 for this\n&gt; earlier message", want{map[string]int{"UA": 1}, []string{"UA"}, "for this"}},
+		{"<@UA> want to grab a beer later?", want{}},
+		{"I love rootbeer <@UA>", want{}},
+		{"<@UA> 3 people asked for beer", want{}},
+	}
+	for _, c := range cases {
+		gift := bot.parseGift(c.text)
+		if c.want.recipients == nil {
+			if gift != nil {
+				t.Errorf("%q: expected no gift, got %+v", c.text, gift)
+			}
+			continue
+		}
+		if gift == nil {
+			t.Errorf("%q: expected a gift", c.text)
+			continue
+		}
+		if len(gift.Recipients) != len(c.want.order) {
+			t.Errorf("%q: expected %d recipients, got %+v", c.text, len(c.want.order), gift.Recipients)
+			continue
+		}
+		for i, g := range gift.Recipients {
+			if g.recipient != c.want.order[i] || g.quantity != c.want.recipients[g.recipient] {
+				t.Errorf("%q: recipient %d = %+v, want %s x%d", c.text, i, g, c.want.order[i], c.want.recipients[c.want.order[i]])
+			}
+		}
+		if gift.Reason != c.want.reason {
+			t.Errorf("%q: reason %q, want %q", c.text, gift.Reason, c.want.reason)
+		}
+	}
+}
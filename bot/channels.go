@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+// Channel types that can be used in allow/deny lists instead of channel IDs.
+var channelTypes = map[string]bool{"public": true, "private": true, "im": true, "mpim": true}
+
+var (
+	channelIDPattern      = regexp.MustCompile(`^[CGD][A-Z0-9]+$`)
+	channelMentionPattern = regexp.MustCompile(`^<#([A-Z0-9]+)(?:\|[^>]*)?>$`)
+)
+
+// channelPolicy decides in which channels gifting counts. Entries are channel IDs
+// or channel types. Deny entries always win; when the allow list is non-empty a
+// channel must match one of its entries. Entries from the environment are fixed for
+// the process lifetime, entries from the store can be changed with /beer-admin.
+type channelPolicy struct {
+	mu       sync.RWMutex
+	envAllow map[string]bool
+	envDeny  map[string]bool
+	dbAllow  map[string]bool
+	dbDeny   map[string]bool
+
+	// channel ID -> type cache for events that do not carry channel_type
+	types map[string]string
+}
+
+// newChannelPolicy builds a policy from comma separated environment lists.
+func newChannelPolicy(allow, deny string) (*channelPolicy, error) {
+	p := &channelPolicy{
+		envAllow: map[string]bool{},
+		envDeny:  map[string]bool{},
+		dbAllow:  map[string]bool{},
+		dbDeny:   map[string]bool{},
+		types:    map[string]string{},
+	}
+	for list, v := range map[string]string{"allow": allow, "deny": deny} {
+		for _, raw := range strings.Split(v, ",") {
+			if strings.TrimSpace(raw) == "" {
+				continue
+			}
+			entry, err := normalizeChannelEntry(raw)
+			if err != nil {
+				return nil, err
+			}
+			if list == "allow" {
+				p.envAllow[entry] = true
+			} else {
+				p.envDeny[entry] = true
+			}
+		}
+	}
+	return p, nil
+}
+
+// normalizeChannelEntry validates an allow/deny entry: a channel type, a channel ID
+// or a Slack channel mention (<#C123|name>).
+func normalizeChannelEntry(raw string) (string, error) {
+	v := strings.TrimSpace(raw)
+	if m := channelMentionPattern.FindStringSubmatch(v); m != nil {
+		v = m[1]
+	}
+	if t := strings.ToLower(v); channelTypes[t] {
+		return t, nil
+	}
+	if id := strings.ToUpper(v); channelIDPattern.MatchString(id) {
+		return id, nil
+	}
+	return "", fmt.Errorf("invalid channel entry %q: use a channel ID or one of public, private, im, mpim", raw)
+}
+
+// load replaces the runtime entries with the rules stored in SQLite.
+func (p *channelPolicy) load(ctx context.Context, store storage.Store) error {
+	rules, err := store.GetChannelRules(ctx)
+	if err != nil {
+		return err
+	}
+	allow, deny := map[string]bool{}, map[string]bool{}
+	for _, r := range rules {
+		if r.List == "allow" {
+			allow[r.Entry] = true
+		} else if r.List == "deny" {
+			deny[r.Entry] = true
+		}
+	}
+	p.mu.Lock()
+	p.dbAllow, p.dbDeny = allow, deny
+	p.mu.Unlock()
+	return nil
+}
+
+// allows reports whether gifts in the channel count. channelType is one of
+// public, private, im, mpim or empty when unknown.
+func (p *channelPolicy) allows(channel, channelType string) bool {
+	if p == nil {
+		return true
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	match := func(set map[string]bool) bool {
+		return set[channel] || (channelType != "" && set[channelType])
+	}
+	if match(p.envDeny) || match(p.dbDeny) {
+		return false
+	}
+	if len(p.envAllow) == 0 && len(p.dbAllow) == 0 {
+		return true
+	}
+	return match(p.envAllow) || match(p.dbAllow)
+}
+
+// usesTypes reports whether any entry is a channel type, i.e. whether callers
+// need to resolve the type of a channel before asking allows.
+func (p *channelPolicy) usesTypes() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, set := range []map[string]bool{p.envAllow, p.envDeny, p.dbAllow, p.dbDeny} {
+		for e := range set {
+			if channelTypes[e] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// describe renders both lists for /beer-admin channels, marking fixed env entries.
+func (p *channelPolicy) describe() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	render := func(env, db map[string]bool) string {
+		var items []string
+		for e := range env {
+			items = append(items, formatChannelEntry(e)+" (env)")
+		}
+		for e := range db {
+			if !env[e] {
+				items = append(items, formatChannelEntry(e))
+			}
+		}
+		if len(items) == 0 {
+			return "(empty)"
+		}
+		sort.Strings(items)
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("*Allow:* %s\n*Deny:* %s", render(p.envAllow, p.dbAllow), render(p.envDeny, p.dbDeny))
+}
+
+// isEnvEntry reports whether an entry comes from the environment and cannot be removed at runtime.
+func (p *channelPolicy) isEnvEntry(list, entry string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if list == "allow" {
+		return p.envAllow[entry]
+	}
+	return p.envDeny[entry]
+}
+
+// formatChannelEntry renders channel IDs as Slack channel links and types verbatim.
+func formatChannelEntry(entry string) string {
+	if channelTypes[entry] {
+		return "`" + entry + "`"
+	}
+	return "<#" + entry + ">"
+}
+
+// eventChannelType maps the channel_type of message events to policy types.
+func eventChannelType(t string) string {
+	switch t {
+	case "channel":
+		return "public"
+	case "group":
+		return "private"
+	case "im", "mpim":
+		return t
+	}
+	return ""
+}
+
+// channelAllowed applies the channel policy. hint is the event's channel_type
+// (may be empty); when the policy uses type entries and no hint is available
+// the type is looked up via conversations.info and cached.
+func (bot *MinimalSlackBot) channelAllowed(channel, hint string) bool {
+	if bot.channels == nil {
+		return true
+	}
+	channelType := eventChannelType(hint)
+	if channelType == "" && bot.channels.usesTypes() {
+		channelType = bot.lookupChannelType(channel)
+	}
+	return bot.channels.allows(channel, channelType)
+}
+
+// lookupChannelType resolves a channel's policy type, caching the result.
+func (bot *MinimalSlackBot) lookupChannelType(channel string) string {
+	p := bot.channels
+	p.mu.RLock()
+	t, ok := p.types[channel]
+	p.mu.RUnlock()
+	if ok {
+		return t
+	}
+	if strings.HasPrefix(channel, "D") {
+		t = "im"
+	} else if bot.api != nil {
+		info, err := bot.api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channel})
+		if err != nil {
+			bot.logger.Debug().Err(err).Str("channel", channel).Msg("Failed to look up channel type")
+			return ""
+		}
+		switch {
+		case info.IsMpIM:
+			t = "mpim"
+		case info.IsIM:
+			t = "im"
+		case info.IsPrivate:
+			t = "private"
+		default:
+			t = "public"
+		}
+	}
+	p.mu.Lock()
+	p.types[channel] = t
+	p.mu.Unlock()
+	return t
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	_ "modernc.org/sqlite"
+)
+
+func TestChannelPolicy_Allows(t *testing.T) {
+	p, err := newChannelPolicy("C111,private", "im,C222")
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	cases := []struct {
+		channel, channelType string
+		want                 bool
+	}{
+		{"C111", "public", true},   // allowed by ID
+		{"G333", "private", true},  // allowed by type
+		{"C444", "public", false},  // not on the allow list
+		{"C222", "private", false}, // deny wins over allowed type
+		{"D555", "im", false},      // denied type
+	}
+	for _, c := range cases {
+		if got := p.allows(c.channel, c.channelType); got != c.want {
+			t.Fatalf("allows(%s, %s) = %v, want %v", c.channel, c.channelType, got, c.want)
+		}
+	}
+
+	open, _ := newChannelPolicy("", "")
+	if !open.allows("C999", "") {
+		t.Fatalf("empty policy must allow every channel")
+	}
+}
+
+func TestNormalizeChannelEntry(t *testing.T) {
+	for in, want := range map[string]string{"<#C123|general>": "C123", "c123": "C123", "MPIM": "mpim", " public ": "public"} {
+		got, err := normalizeChannelEntry(in)
+		if err != nil || got != want {
+			t.Fatalf("normalize(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := normalizeChannelEntry("#general"); err == nil {
+		t.Fatalf("expected error for channel name")
+	}
+}
+
+func TestAdminChannels_PersistsRules(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := storage.NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	policy, _ := newChannelPolicy("", "")
+	bot := &MinimalSlackBot{store: s, channels: policy}
+
+	if reply := bot.adminChannels(ctx, []string{"deny", "mpim"}); !strings.Contains(reply, "Added") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	if policy.allows("G1", "mpim") {
+		t.Fatalf("mpim must be denied after /beer-admin channels deny mpim")
+	}
+
+	// a fresh policy loaded from the same store sees the rule
+	reloaded, _ := newChannelPolicy("", "")
+	if err := reloaded.load(ctx, s); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if reloaded.allows("G1", "mpim") {
+		t.Fatalf("stored deny rule not loaded")
+	}
+
+	if reply := bot.adminChannels(ctx, []string{"remove", "deny", "mpim"}); !strings.Contains(reply, "Removed") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	if !policy.allows("G1", "mpim") {
+		t.Fatalf("mpim must be allowed after removing the rule")
+	}
+}
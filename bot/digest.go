@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// digestCatchUp is how late a scheduled digest may still be posted, e.g. when the
+// bot was restarting at the scheduled minute.
+const digestCatchUp = time.Hour
+
+// digestConfig is the scheduled channel digest (DIGEST_SCHEDULE, DIGEST_CHANNEL,
+// DIGEST_PERIOD).
+type digestConfig struct {
+	schedule *cronSchedule
+	channel  string
+	period   string // today, week, month, quarter or year
+}
+
+// name identifies the digest's runs in digest_runs.
+func (d *digestConfig) name() string {
+	return "digest:" + d.channel
+}
+
+// newDigestConfig reads the digest settings; it returns nil when no schedule is set.
+func newDigestConfig() (*digestConfig, error) {
+	expr := strings.TrimSpace(os.Getenv("DIGEST_SCHEDULE"))
+	if expr == "" {
+		return nil, nil
+	}
+	schedule, err := parseCron(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DIGEST_SCHEDULE: %w", err)
+	}
+	channel := strings.TrimSpace(os.Getenv("DIGEST_CHANNEL"))
+	if channel == "" {
+		return nil, fmt.Errorf("DIGEST_CHANNEL is required when DIGEST_SCHEDULE is set")
+	}
+	period := strings.ToLower(envOr("DIGEST_PERIOD", "week"))
+	switch period {
+	case "today", "week", "month", "quarter", "year":
+	default:
+		return nil, fmt.Errorf("invalid DIGEST_PERIOD %q: use today, week, month, quarter or year", period)
+	}
+	return &digestConfig{schedule: schedule, channel: channel, period: period}, nil
+}
+
+// runScheduledDigest posts the channel digest at every scheduled time (in the
+// workspace time zone) until the bot stops. A slot missed by less than
+// digestCatchUp is posted late; slots that were already posted are skipped.
+func (bot *MinimalSlackBot) runScheduledDigest(ctx context.Context) {
+	d := bot.digest
+	slot := d.schedule.next(time.Now().Add(-digestCatchUp).In(bot.workspaceLocation()))
+	for !slot.IsZero() {
+		timer := time.NewTimer(time.Until(slot))
+		select {
+		case <-bot.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		bot.postScheduledDigest(ctx, slot)
+		slot = d.schedule.next(slot)
+	}
+	bot.logger.Warn().Msg("DIGEST_SCHEDULE never matches; scheduled digests stopped")
+}
+
+// postScheduledDigest posts the digest for one slot unless it was already claimed.
+func (bot *MinimalSlackBot) postScheduledDigest(ctx context.Context, slot time.Time) {
+	d := bot.digest
+	claimed, err := bot.store.ClaimDigestRun(ctx, d.name(), slot)
+	if err != nil {
+		bot.logger.Error().Err(err).Time("slot", slot).Msg("Failed to claim digest run")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	if !claimed {
+		bot.logger.Info().Time("slot", slot).Msg("Digest already posted for this slot")
+		return
+	}
+
+	status, ts := "posted", ""
+	blocks, text, err := bot.channelDigest(ctx, d.period, slot)
+	if err == nil {
+		_, ts, err = bot.api.PostMessage(d.channel, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...))
+	}
+	if err != nil {
+		status = "failed"
+		bot.logger.Error().Err(err).Str("channel", d.channel).Time("slot", slot).Msg("Failed to post digest")
+		bot.errorCounter.WithLabelValues("message_error").Inc()
+	} else {
+		bot.eventCounter.WithLabelValues("digest", "posted").Inc()
+	}
+	if err := bot.store.FinishDigestRun(ctx, d.name(), slot, status, d.channel, ts); err != nil {
+		bot.logger.Error().Err(err).Time("slot", slot).Msg("Failed to record digest run")
+	}
+}
+
+// previousRange shifts a period's range back by one period, so that "this week up
+// to Friday" is compared with "last week up to Friday".
+func previousRange(period string, start, end time.Time) (time.Time, time.Time) {
+	shift := func(t time.Time) time.Time {
+		switch period {
+		case "today":
+			return t.AddDate(0, 0, -1)
+		case "month":
+			return t.AddDate(0, -1, 0)
+		case "quarter":
+			return t.AddDate(0, -3, 0)
+		case "year":
+			return t.AddDate(-1, 0, 0)
+		}
+		return t.AddDate(0, 0, -7)
+	}
+	return shift(start), shift(end)
+}
+
+// mover is a recipient whose received total changed most versus the previous period.
+type mover struct {
+	user        string
+	before, now int
+}
+
+// biggestMovers returns up to limit recipients with the largest gains.
+func biggestMovers(current, previous [][2]string, limit int) []mover {
+	totals := map[string]*mover{}
+	get := func(user string) *mover {
+		if totals[user] == nil {
+			totals[user] = &mover{user: user}
+		}
+		return totals[user]
+	}
+	for _, r := range current {
+		get(r[0]).now, _ = strconv.Atoi(r[1])
+	}
+	for _, r := range previous {
+		get(r[0]).before, _ = strconv.Atoi(r[1])
+	}
+	var out []mover
+	for _, m := range totals {
+		if m.now > m.before {
+			out = append(out, *m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		di, dj := out[i].now-out[i].before, out[j].now-out[j].before
+		if di != dj {
+			return di > dj
+		}
+		return out[i].user < out[j].user
+	})
+	return out[:min(limit, len(out))]
+}
+
+// channelDigest builds the digest for the period ending at the slot: totals,
+// top givers and receivers, newcomers and the biggest movers.
+func (bot *MinimalSlackBot) channelDigest(ctx context.Context, period string, at time.Time) ([]slack.Block, string, error) {
+	st := statsState{Period: period, Size: defaultStatsPageSize}
+	start, end := st.statsRange(at.In(bot.workspaceLocation()))
+	prevStart, prevEnd := previousRange(period, start, end)
+
+	total, err := bot.store.TotalBeers(ctx, start, end)
+	if err != nil {
+		return nil, "", err
+	}
+	prevTotal, err := bot.store.TotalBeers(ctx, prevStart, prevEnd)
+	if err != nil {
+		return nil, "", err
+	}
+	givers, err := bot.store.TopGivers(ctx, start, end, st.Size)
+	if err != nil {
+		return nil, "", err
+	}
+	// Movers need every recipient of both periods, not just the top
+	receivers, err := bot.store.TopReceivers(ctx, start, end, 1000)
+	if err != nil {
+		return nil, "", err
+	}
+	prevReceivers, err := bot.store.TopReceivers(ctx, prevStart, prevEnd, 1000)
+	if err != nil {
+		return nil, "", err
+	}
+	newcomers, err := bot.store.Newcomers(ctx, start, end)
+	if err != nil {
+		return nil, "", err
+	}
+
+	lb := &leaderboard{
+		state:     st,
+		subtitle:  st.describe(start, end),
+		givers:    rankRows(givers)[:min(st.Size, len(givers))],
+		receivers: rankRows(receivers)[:min(st.Size, len(receivers))],
+	}
+	var ids []string
+	for _, r := range append(append([]leaderRow(nil), lb.givers...), lb.receivers...) {
+		ids = append(ids, r.user)
+	}
+	lb.profiles = bot.userProfiles(ids)
+
+	md := func(t string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, t, false, false)
+	}
+	change := "same as the previous period"
+	switch {
+	case total > prevTotal:
+		change = fmt.Sprintf("▲ %d vs the previous period", total-prevTotal)
+	case total < prevTotal:
+		change = fmt.Sprintf("▼ %d vs the previous period", prevTotal-total)
+	}
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "🍻 Beer digest", false, false)),
+		slack.NewContextBlock("", md(lb.subtitle)),
+		slack.NewSectionBlock(md(fmt.Sprintf("*%s* given · %s", beerCount(total), change)), nil, nil),
+	}
+	blocks = append(blocks, lb.rankingBlocks()...)
+
+	if len(newcomers) > 0 {
+		var names []string
+		for _, u := range newcomers[:min(10, len(newcomers))] {
+			names = append(names, fmt.Sprintf("<@%s>", u))
+		}
+		if len(newcomers) > 10 {
+			names = append(names, fmt.Sprintf("%d more", len(newcomers)-10))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(md("*👋 New faces*\n"+joinWithAnd(names)), nil, nil))
+	}
+	if movers := biggestMovers(receivers, prevReceivers, 3); len(movers) > 0 {
+		var lines []string
+		for _, m := range movers {
+			lines = append(lines, fmt.Sprintf("• <@%s> +%d (%d → %d)", m.user, m.now-m.before, m.before, m.now))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(md("*📈 Biggest movers*\n"+strings.Join(lines, "\n")), nil, nil))
+	}
+	return blocks, fmt.Sprintf("Beer digest — %s: %s given", lb.subtitle, beerCount(total)), nil
+}
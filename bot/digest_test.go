@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	_ "modernc.org/sqlite"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, 5, 15, 16, 30, 0, 0, time.UTC) // a Wednesday
+	cases := []struct {
+		expr, want string
+	}{
+		{"0 16 * * 5", "2024-05-17 16:00"},       // Fridays at 16:00
+		{"*/15 * * * *", "2024-05-15 16:45"},     // every quarter hour
+		{"30 9 1 * *", "2024-06-01 09:30"},       // monthly
+		{"0 8-18/2 * * 1-5", "2024-05-15 18:00"}, // every other working hour
+		{"0 0 13 * 5", "2024-05-17 00:00"},       // the 13th or a Friday
+		{"@weekly", "2024-05-19 00:00"},
+	}
+	for _, c := range cases {
+		s, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := s.next(from).Format("2006-01-02 15:04"); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.expr, c.want, got)
+		}
+	}
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "0 0 * * 8", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	if s, _ := parseCron("0 0 30 2 *"); !s.next(from).IsZero() {
+		t.Errorf("February 30th must never match")
+	}
+}
+
+func TestScheduledDigest(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "digest.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := storage.NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	friday := time.Date(2024, 5, 17, 16, 0, 0, 0, time.UTC)
+	lastWeek := friday.AddDate(0, 0, -7)
+	gifts := []struct {
+		giver, recipient, ts string
+		at                   time.Time
+		count                int
+	}{
+		{"UA", "UB", "1.1", lastWeek, 3},
+		{"UA", "UC", "1.2", lastWeek, 1},
+		{"UA", "UC", "2.1", friday.Add(-time.Hour), 4},
+		{"UB", "UD", "2.2", friday.Add(-2 * time.Hour), 1}, // UD is new
+	}
+	for _, g := range gifts {
+		if err := s.AddBeer(ctx, g.giver, g.recipient, g.ts, g.at, g.count, storage.BeerNote{}); err != nil {
+			t.Fatalf("addbeer: %v", err)
+		}
+	}
+
+	var sent []string
+	schedule, _ := parseCron("0 16 * * 5")
+	bot := newTestBot(t, s, func(b *MinimalSlackBot) {
+		b.api = dmRecorder(t, &sent)
+		b.digest = &digestConfig{schedule: schedule, channel: "CDIGEST", period: "week"}
+	})
+
+	bot.postScheduledDigest(ctx, friday)
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "CDIGEST: Beer digest") || !strings.Contains(sent[0], "5 beers given") {
+		t.Fatalf("unexpected digest %q", sent)
+	}
+	blocks, _, err := bot.channelDigest(ctx, "week", friday)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	var text strings.Builder
+	enc := json.NewEncoder(&text)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(blocks); err != nil {
+		t.Fatalf("marshal blocks: %v", err)
+	}
+	for _, want := range []string{"▲ 1 vs the previous period", "New faces", "<@UD>", "<@UC> +3 (1 → 4)"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("expected %q in digest:\n%s", want, text.String())
+		}
+	}
+
+	// a restart that reaches the same slot again does not post twice
+	bot.postScheduledDigest(ctx, friday)
+	if len(sent) != 1 {
+		t.Fatalf("digest posted twice: %q", sent)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// handleMessageChanged reconciles the gifts recorded for an edited message with its
+// new text: counts are adjusted, recipients that disappeared lose their beers and
+// new recipients receive theirs. Edits only amend messages that already recorded a
+// gift; editing an unrelated message into a beer message does not create one.
+func (bot *MinimalSlackBot) handleMessageChanged(ctx context.Context, event *slackevents.MessageEvent, envelopeID string) {
+	msg := event.Message
+	if msg == nil || msg.User == "" || msg.BotID != "" || msg.Timestamp == "" {
+		return
+	}
+	if prev := event.PreviousMessage; prev != nil && prev.Text == msg.Text {
+		return // link unfurls and thread reply updates resend the same text
+	}
+	giver, ts := msg.User, msg.Timestamp
+
+	existing, err := bot.store.GetBeersByTS(ctx, giver, ts)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", giver).Str("ts", ts).Msg("Failed to load gifts for edited message")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	if len(existing) == 0 {
+		return
+	}
+
+	var desired []recipientGift
+	note := storage.BeerNote{ChannelID: event.Channel}
+	if gift := bot.parseGift(msg.Text); gift != nil {
+		desired = gift.Recipients
+		note.Reason = gift.Reason
+	}
+	// The gift stays on the day of the original message
+	eventTime := parseSlackTS(ts).In(bot.userLocation(giver))
+
+	// Compare the stored rows with what the new text would deliver: capped, without
+	// the giver and within the day's budget, which the message's own beers are back in
+	recorded := 0
+	for _, n := range existing {
+		recorded += n
+	}
+	remaining, err := bot.remainingBudget(ctx, giver, eventTime, recorded)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", giver).Msg("Failed to check daily beer budget for edited message")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	wanted := map[string]bool{}
+	var changed []recipientGift
+	credit := 0
+	for _, g := range desired {
+		if g.recipient == giver {
+			continue
+		}
+		wanted[g.recipient] = true
+		g.quantity = min(g.quantity, bot.maxGift)
+		effective := g.quantity
+		if remaining >= 0 {
+			effective = min(effective, remaining)
+			remaining -= effective
+		}
+		if existing[g.recipient] == effective {
+			continue
+		}
+		credit += existing[g.recipient]
+		changed = append(changed, g)
+	}
+	var dropped []string
+	for recipient := range existing {
+		if !wanted[recipient] {
+			dropped = append(dropped, recipient)
+		}
+	}
+	sort.Strings(dropped)
+	if len(changed) == 0 && len(dropped) == 0 {
+		return // e.g. a link unfurl or a typo fix that does not touch the gift
+	}
+
+	dedupKey := envelopeID
+	if dedupKey == "" {
+		dedupKey = "message_changed:" + ts + ":" + event.EventTimeStamp
+	}
+	if !bot.markEventProcessed(ctx, dedupKey, giver, eventTime) {
+		return
+	}
+
+	revoked := bot.revokeGifts(ctx, dedupKey, giver, ts, dropped, existing, eventTime)
+	amended := bot.deliverGifts(ctx, giftRequest{
+		dedupKey:     dedupKey,
+		giver:        giver,
+		channel:      event.Channel,
+		ts:           ts,
+		eventTime:    eventTime,
+		gifts:        changed,
+		note:         note,
+		status:       "amended",
+		budgetCredit: credit,
+	})
+
+	if len(amended) > 0 || len(revoked) > 0 {
+		bot.postEphemeral(event.Channel, giver, formatEditSummary(amended, revoked))
+	}
+}
+
+// handleMessageDeleted removes every gift recorded for a deleted message.
+func (bot *MinimalSlackBot) handleMessageDeleted(ctx context.Context, event *slackevents.MessageEvent, envelopeID string) {
+	prev := event.PreviousMessage
+	if prev == nil || prev.User == "" {
+		return
+	}
+	giver := prev.User
+	ts := event.DeletedTimeStamp
+	if ts == "" {
+		ts = prev.Timestamp
+	}
+
+	existing, err := bot.store.GetBeersByTS(ctx, giver, ts)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", giver).Str("ts", ts).Msg("Failed to load gifts for deleted message")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	if len(existing) == 0 {
+		return
+	}
+
+	dedupKey := envelopeID
+	if dedupKey == "" {
+		dedupKey = "message_deleted:" + ts
+	}
+	eventTime := parseSlackTS(ts).In(bot.userLocation(giver))
+	if !bot.markEventProcessed(ctx, dedupKey, giver, eventTime) {
+		return
+	}
+
+	recipients := make([]string, 0, len(existing))
+	for r := range existing {
+		recipients = append(recipients, r)
+	}
+	sort.Strings(recipients)
+	bot.revokeGifts(ctx, dedupKey, giver, ts, recipients, existing, eventTime)
+}
+
+// revokeGifts revokes the given recipients' gifts of one message and records a
+// "revoked" outcome for each. The rows are kept with revoked_at set, as for an
+// undo, and the Home tabs of everyone involved are refreshed. It returns the
+// recipients whose gifts were revoked.
+func (bot *MinimalSlackBot) revokeGifts(ctx context.Context, dedupKey, giver, ts string, recipients []string, counts map[string]int, eventTime time.Time) []string {
+	var revoked []string
+	now := time.Now()
+	for _, recipient := range recipients {
+		if bot.readOnly {
+			bot.logger.Info().Str("mode", "read-only").Msg("Skipping DB write (READ_ONLY enabled)")
+		} else if err := bot.store.RevokeBeer(ctx, giver, recipient, ts, now); err != nil {
+			_ = bot.store.RecordBeerEventOutcome(ctx, dedupKey, giver, recipient, counts[recipient], "error", eventTime)
+			bot.logger.Error().
+				Err(err).
+				Str("giver", giver).
+				Str("recipient", recipient).
+				Str("ts", ts).
+				Msg("Failed to revoke beer")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			continue
+		}
+		_ = bot.store.RecordBeerEventOutcome(ctx, dedupKey, giver, recipient, counts[recipient], "revoked", eventTime)
+		bot.eventCounter.WithLabelValues("beer_giving", "revoked").Inc()
+		revoked = append(revoked, recipient)
+	}
+	if len(revoked) > 0 {
+		bot.refreshHomes(ctx, append([]string{giver}, revoked...)...)
+	}
+	return revoked
+}
+
+// formatEditSummary tells the giver how an edit changed their gift.
+func formatEditSummary(amended []recipientGift, revoked []string) string {
+	var parts []string
+	for _, g := range amended {
+		parts = append(parts, fmt.Sprintf("<@%s> now gets %s", g.recipient, beerCount(g.quantity)))
+	}
+	for _, r := range revoked {
+		parts = append(parts, fmt.Sprintf("<@%s> no longer gets any", r))
+	}
+	return "✏️ Your beer message was updated: " + joinWithAnd(parts) + "."
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Gift is the structured result of parsing a beer message.
+type Gift struct {
+	Recipients []recipientGift // in order of appearance, one entry per user
+	Emoji      []string        // vocabulary emoji that made up the gift, in order
+	Reason     string          // the free text after the gift, e.g. "for fixing the prod outage"
+}
+
+type tokenKind int
+
+const (
+	tokSpace   tokenKind = iota
+	tokWord              // plain word
+	tokPunct             // single punctuation rune or HTML entity (&amp;)
+	tokNumber            // integer
+	tokMention           // <@U123> or <@U123|name>
+	tokEmoji             // vocabulary emoji
+	tokKeyword           // vocabulary keyword ("beer")
+	tokVerb              // vocabulary verb ("give")
+	tokOpaque            // code span/block, link or other mrkdwn that never carries gift syntax
+	tokQuote             // quoted line ("&gt; …"), never part of a gift or its reason
+)
+
+// token is one lexical unit of a Slack mrkdwn message.
+type token struct {
+	kind  tokenKind
+	text  string // raw text
+	user  string // tokMention: user ID
+	value int    // tokNumber: value, tokEmoji: weight
+}
+
+var (
+	mentionTokenPattern = regexp.MustCompile(`^<@([A-Z0-9]+)(?:\|[^>]*)?>$`)
+	entityPattern       = regexp.MustCompile(`^&[a-z]+;`)
+)
+
+// tokenize splits Slack mrkdwn into tokens. Code spans and blocks, angle-bracket
+// constructs other than user mentions (links, channels, <!here>) and quoted lines
+// become single opaque tokens so numbers and emoji inside them are never read as
+// part of a gift.
+func tokenize(text string, vocab *giftVocabulary) []token {
+	var toks []token
+	emit := func(kind tokenKind, s string) { toks = append(toks, token{kind: kind, text: s}) }
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		lineStart := i == 0 || text[i-1] == '\n'
+		r, size := utf8.DecodeRuneInString(rest)
+
+		switch {
+		case lineStart && (strings.HasPrefix(rest, "&gt;") || strings.HasPrefix(rest, ">")):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			emit(tokQuote, rest[:end])
+			i += end
+		case strings.HasPrefix(rest, "```"):
+			end := len(rest)
+			if j := strings.Index(rest[3:], "```"); j >= 0 {
+				end = j + 6
+			}
+			emit(tokOpaque, rest[:end])
+			i += end
+		case r == '`':
+			j := strings.IndexByte(rest[1:], '`')
+			if j < 0 {
+				emit(tokPunct, "`")
+				i++
+				continue
+			}
+			emit(tokOpaque, rest[:j+2])
+			i += j + 2
+		case r == '<':
+			j := strings.IndexByte(rest, '>')
+			if j < 0 {
+				emit(tokPunct, "<")
+				i++
+				continue
+			}
+			raw := rest[:j+1]
+			if m := mentionTokenPattern.FindStringSubmatch(raw); m != nil {
+				toks = append(toks, token{kind: tokMention, text: raw, user: m[1]})
+			} else {
+				emit(tokOpaque, raw)
+			}
+			i += j + 1
+		case unicode.IsSpace(r):
+			j := strings.IndexFunc(rest, func(c rune) bool { return !unicode.IsSpace(c) })
+			if j < 0 {
+				j = len(rest)
+			}
+			emit(tokSpace, rest[:j])
+			i += j
+		case vocab.emojiPrefix != nil && vocab.emojiPrefix.MatchString(rest):
+			m := vocab.emojiPrefix.FindString(rest)
+			toks = append(toks, token{kind: tokEmoji, text: m, value: vocab.emojiWeight(m)})
+			i += len(m)
+		case r == '&' && entityPattern.MatchString(rest):
+			m := entityPattern.FindString(rest)
+			emit(tokPunct, m)
+			i += len(m)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := strings.IndexFunc(rest, func(c rune) bool {
+				return !(unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-' || c == '\'')
+			})
+			if j < 0 {
+				j = len(rest)
+			}
+			word := rest[:j]
+			lower := strings.ToLower(word)
+			switch {
+			case isAllDigits(word):
+				n, err := strconv.Atoi(word)
+				if err != nil {
+					emit(tokWord, word)
+				} else {
+					toks = append(toks, token{kind: tokNumber, text: word, value: n})
+				}
+			case vocab.keywordSet[lower]:
+				emit(tokKeyword, word)
+			case vocab.verbSet[lower]:
+				emit(tokVerb, word)
+			default:
+				emit(tokWord, word)
+			}
+			i += j
+		default:
+			emit(tokPunct, rest[:size])
+			i += size
+		}
+	}
+	return toks
+}
+
+func isAllDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// giftParser walks the non-space tokens of a message.
+type giftParser struct {
+	toks  []token
+	items []int  // indices of non-space tokens
+	used  []bool // per token: consumed by the gift structure (excluded from the reason)
+}
+
+func (ps *giftParser) kind(p int) tokenKind {
+	if p < 0 || p >= len(ps.items) {
+		return tokSpace
+	}
+	return ps.toks[ps.items[p]].kind
+}
+
+func (ps *giftParser) tok(p int) token { return ps.toks[ps.items[p]] }
+
+func (ps *giftParser) isWord(p int, words ...string) bool {
+	if ps.kind(p) != tokWord && ps.kind(p) != tokPunct {
+		return false
+	}
+	t := strings.ToLower(ps.tok(p).text)
+	for _, w := range words {
+		if t == w {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps *giftParser) mark(start, end int) {
+	for p := start; p < end; p++ {
+		ps.used[ps.items[p]] = true
+	}
+}
+
+// cluster is a run of beer tokens: an optional number followed by emoji or a keyword.
+type cluster struct {
+	start, end int // item range
+	next       int // item after the cluster and an optional "to"
+	quantity   int
+	emoji      []string
+}
+
+// clusterAt returns the cluster starting at item p. An explicit number wins over
+// the emoji weights ("3 🍺" is three beers); a bare keyword is one beer.
+func (ps *giftParser) clusterAt(p int) (cluster, bool) {
+	q, num := p, 0
+	if ps.kind(q) == tokNumber && ps.tok(q).value > 0 {
+		num = ps.tok(q).value
+		q++
+	}
+	c := cluster{start: p}
+	switch {
+	case ps.kind(q) == tokEmoji:
+		for ps.kind(q) == tokEmoji {
+			c.quantity += ps.tok(q).value
+			c.emoji = append(c.emoji, ps.tok(q).text)
+			q++
+		}
+	case ps.kind(q) == tokKeyword:
+		c.quantity = 1
+		q++
+	default:
+		return cluster{}, false
+	}
+	if num > 0 {
+		c.quantity = num
+	}
+	c.end, c.next = q, q
+	if ps.isWord(q, "to") {
+		c.next = q + 1
+	}
+	return c, true
+}
+
+// groupAt returns the mention group starting at item p: adjacent mentions,
+// optionally joined by ",", "and" or "&".
+func (ps *giftParser) groupAt(p int) (end int, users []string, ok bool) {
+	if ps.kind(p) != tokMention {
+		return 0, nil, false
+	}
+	users = []string{ps.tok(p).user}
+	q := p + 1
+	for {
+		if ps.kind(q) == tokMention {
+			users = append(users, ps.tok(q).user)
+			q++
+			continue
+		}
+		if ps.isWord(q, ",", "and", "&", "&amp;") && ps.kind(q+1) == tokMention {
+			users = append(users, ps.tok(q+1).user)
+			q += 2
+			continue
+		}
+		return q, users, true
+	}
+}
+
+// trailingAt returns the cluster that follows a mention group ending at item p,
+// allowing a giving verb in between ("<@A> gives 3 beers").
+func (ps *giftParser) trailingAt(p int) (cluster, bool) {
+	if ps.kind(p) == tokVerb {
+		if c, ok := ps.clusterAt(p + 1); ok {
+			c.start = p
+			return c, true
+		}
+		return cluster{}, false
+	}
+	return ps.clusterAt(p)
+}
+
+// groupFollows reports whether a mention group starts at item p (possibly after "to").
+func (ps *giftParser) groupFollows(p int) bool {
+	return ps.kind(p) == tokMention || (ps.isWord(p, "to") && ps.kind(p+1) == tokMention)
+}
+
+// parseGift parses a message into a Gift, or returns nil when it is not a beer gift.
+//
+// A gift is a mention group directly next to a beer cluster: "🍺🍺 <@A> <@B>",
+// "<@A> 🍺", "give <@A> 3 beers", "<@A> gives beer", "5 beers to <@A>". Numbers only
+// count as quantities when they stand right before beer emoji or keywords, so
+// "<@A> 🍺 for fixing 3 bugs" is one beer. When a message names several groups,
+// its first element decides the convention: mention-first messages attach each
+// cluster to the group before it ("<@A> 🍺🍺 <@B> 🍺"), emoji-first messages to the
+// group after it ("🍺 <@A> 🍺🍺 <@B>"). Mentions that are not part of a gift stay in
+// the reason text.
+func (bot *MinimalSlackBot) parseGift(text string) *Gift {
+	ps := &giftParser{toks: tokenize(text, bot.vocabulary())}
+	ps.used = make([]bool, len(ps.toks))
+	for i, t := range ps.toks {
+		if t.kind != tokSpace {
+			ps.items = append(ps.items, i)
+		}
+	}
+
+	mentionFirst := true
+	for p := range ps.items {
+		if ps.kind(p) == tokMention {
+			break
+		}
+		if _, ok := ps.clusterAt(p); ok {
+			mentionFirst = false
+			break
+		}
+	}
+
+	gift := &Gift{}
+	seen := map[string]bool{}
+	assign := func(users []string, quantity int, emoji []string) {
+		for _, u := range users {
+			if !seen[u] {
+				seen[u] = true
+				gift.Recipients = append(gift.Recipients, recipientGift{recipient: u, quantity: quantity})
+			}
+		}
+		gift.Emoji = append(gift.Emoji, emoji...)
+	}
+	markVerbBefore := func(p int) {
+		if ps.kind(p-1) == tokVerb {
+			ps.mark(p-1, p)
+		}
+	}
+
+	var lead *cluster
+	for p := 0; p < len(ps.items); {
+		if gEnd, users, ok := ps.groupAt(p); ok {
+			hasLead := lead != nil && lead.next == p
+			trail, hasTrail := ps.trailingAt(gEnd)
+			switch {
+			case hasTrail && (mentionFirst || !hasLead):
+				ps.mark(p, trail.end)
+				markVerbBefore(p)
+				assign(users, trail.quantity, trail.emoji)
+				p = trail.end
+			case hasLead:
+				ps.mark(lead.start, gEnd)
+				markVerbBefore(lead.start)
+				quantity, emoji := lead.quantity, lead.emoji
+				p = gEnd
+				// "🍺 <@A> 🍺🍺" — a trailing cluster that does not lead into another group adds up
+				if hasTrail && !ps.groupFollows(trail.end) && !ps.groupFollows(trail.next) {
+					ps.mark(gEnd, trail.end)
+					quantity += trail.quantity
+					emoji = append(append([]string(nil), emoji...), trail.emoji...)
+					p = trail.end
+				}
+				assign(users, quantity, emoji)
+			default:
+				p = gEnd
+			}
+			lead = nil
+			continue
+		}
+		if c, ok := ps.clusterAt(p); ok {
+			lead = &c
+			p = c.next
+			continue
+		}
+		lead = nil
+		p++
+	}
+	if len(gift.Recipients) == 0 {
+		return nil
+	}
+	gift.Reason = ps.reason()
+	return gift
+}
+
+// reason joins the tokens after the start of the gift that are not part of the
+// gift structure. Text before the gift ("I give …") and quoted lines are dropped.
+func (ps *giftParser) reason() string {
+	start := slices.Index(ps.used, true)
+	if start < 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, t := range ps.toks[start:] {
+		if ps.used[start+i] || t.kind == tokEmoji || t.kind == tokQuote {
+			continue
+		}
+		b.WriteString(t.text)
+	}
+	r := strings.Join(strings.Fields(b.String()), " ")
+	return strings.TrimLeft(r, " ,.:;!-–—")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// handleBeerHelp implements /beer-help.
+func (bot *MinimalSlackBot) handleBeerHelp(cmd slack.SlashCommand) {
+	bot.postEphemeral(cmd.ChannelID, cmd.UserID, bot.helpText(cmd.UserID))
+}
+
+// handleAppMention answers "@BeerBot help" (or a bare mention) with the help text,
+// visible only to the caller. Gifts in mentions are handled by the message event.
+func (bot *MinimalSlackBot) handleAppMention(event *slackevents.AppMentionEvent) {
+	if event.BotID != "" || event.User == "" {
+		return
+	}
+	var words []string
+	for _, tok := range tokenize(event.Text, bot.vocabulary()) {
+		if tok.kind == tokWord {
+			words = append(words, strings.ToLower(tok.text))
+		}
+	}
+	if len(words) > 0 && words[0] != "help" {
+		return
+	}
+	bot.eventCounter.WithLabelValues("help", "mention").Inc()
+	bot.postEphemeral(event.Channel, event.User, bot.helpText(event.User))
+}
+
+// giftExamples renders example messages from the active vocabulary, using @alex
+// and @sam as recipients.
+func (bot *MinimalSlackBot) giftExamples() []string {
+	v := bot.vocabulary()
+	var emoji []string
+	for e := range v.emoji {
+		emoji = append(emoji, e)
+	}
+	// Prefer unicode emoji over :shortcodes: as the main example
+	sort.Slice(emoji, func(i, j int) bool {
+		ci, cj := strings.HasPrefix(emoji[i], ":"), strings.HasPrefix(emoji[j], ":")
+		if ci != cj {
+			return cj
+		}
+		return emoji[i] < emoji[j]
+	})
+
+	var examples []string
+	if len(emoji) > 0 {
+		e := emoji[0]
+		examples = append(examples,
+			fmt.Sprintf("`@alex %s` — one beer for Alex", e),
+			fmt.Sprintf("`@alex 3 %s` or `@alex %s%s%s` — three beers", e, e, e, e),
+			fmt.Sprintf("`@alex %s%s @sam %s` — two for Alex, one for Sam", e, e, e),
+			fmt.Sprintf("`%s @alex @sam for the release` — one each, with a reason", e),
+		)
+	}
+	if len(v.keywords) > 0 {
+		k := v.keywords[len(v.keywords)-1]
+		if len(v.verbs) > 0 {
+			examples = append(examples, fmt.Sprintf("`%s @alex 2 %s for the review`", v.verbs[0], k))
+		} else {
+			examples = append(examples, fmt.Sprintf("`@alex 2 %s for the review`", k))
+		}
+	}
+	if len(emoji) > 1 {
+		examples = append(examples, "Also counted: "+strings.Join(emoji[1:], " "))
+	}
+	var weighted []string
+	for _, e := range emoji {
+		if w := v.emoji[e]; w > 1 {
+			weighted = append(weighted, fmt.Sprintf("%s = %s", e, beerCount(w)))
+		}
+	}
+	if len(weighted) > 0 {
+		examples = append(examples, "Worth more: "+strings.Join(weighted, ", "))
+	}
+	return examples
+}
+
+// helpText explains how to give beers with the active vocabulary and limits and
+// lists the commands available to the user.
+func (bot *MinimalSlackBot) helpText(user string) string {
+	var b strings.Builder
+	b.WriteString("*🍺 How to give beers*\nMention someone next to a beer in a message:\n")
+	for _, ex := range bot.giftExamples() {
+		b.WriteString("• " + ex + "\n")
+	}
+	b.WriteString("Numbers only count right before a beer, and code, links and quotes are ignored.\n")
+	if len(bot.reactions) > 0 {
+		var names []string
+		for r := range bot.reactions {
+			names = append(names, ":"+r+":")
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "React with %s to give the author a beer.\n", strings.Join(names, " "))
+	}
+
+	b.WriteString("\n*Limits*\n")
+	fmt.Fprintf(&b, "• Up to %s per person in one message\n", beerCount(bot.maxGift))
+	if bot.maxPerDay > 0 {
+		fmt.Fprintf(&b, "• %s per day in total\n", beerCount(bot.maxPerDay))
+	} else {
+		b.WriteString("• No daily limit\n")
+	}
+	if bot.undoWindow > 0 {
+		fmt.Fprintf(&b, "• Gifts can be undone for %s\n", bot.undoWindow)
+	}
+	if bot.readOnly {
+		b.WriteString("• ⚠️ BeerBot is in read-only mode: gifts are not recorded right now\n")
+	}
+
+	b.WriteString("\n*Commands*\n")
+	commands := []string{
+		"`/beer-give [@user …] [quantity] [reason]` — give beers with a dialog",
+		"`/beer-me` — your beers, budget and recent gifts",
+		"`/beer-stats [today|week|month|quarter|year|all]` — the leaderboard",
+	}
+	if bot.undoWindow > 0 {
+		commands = append(commands, "`/beer-undo` — take back your last gift")
+	}
+	commands = append(commands,
+		"`/beer-settings [notify=off|instant|daily]` — direct messages about beers you receive",
+		"`/beer-help` — this message")
+	for _, c := range commands {
+		b.WriteString("• " + c + "\n")
+	}
+	if bot.isAdmin(user) {
+		b.WriteString("\n*Admin commands*\n")
+		b.WriteString(strings.TrimPrefix(adminUsage, "Usage:\n"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHelpText(t *testing.T) {
+	vocab := mustVocabulary("🍻=2,:prost:", "bier", "gib")
+	bot := &MinimalSlackBot{vocab: vocab, maxGift: 5, maxPerDay: 0, undoWindow: 15 * time.Minute, admins: map[string]bool{"UADMIN": true}}
+
+	// every example in backticks must be understood by the parser
+	code := regexp.MustCompile("`([^`]*)`")
+	examples := bot.giftExamples()
+	for _, ex := range examples {
+		for _, m := range code.FindAllStringSubmatch(ex, -1) {
+			text := strings.NewReplacer("@alex", "<@UALEX>", "@sam", "<@USAM>").Replace(m[1])
+			if bot.parseGift(text) == nil {
+				t.Errorf("example %q is not a gift", m[1])
+			}
+		}
+	}
+	if !strings.Contains(strings.Join(examples, "\n"), "🍻 = 2 beers") {
+		t.Fatalf("expected weighted emoji in examples, got %v", examples)
+	}
+
+	help := bot.helpText("UX")
+	if !strings.Contains(help, "Up to 5 beers") || !strings.Contains(help, "No daily limit") || !strings.Contains(help, "/beer-undo") {
+		t.Fatalf("unexpected help:\n%s", help)
+	}
+	if strings.Contains(help, "/beer-admin") {
+		t.Fatalf("admin commands must be hidden from regular users:\n%s", help)
+	}
+	if !strings.Contains(bot.helpText("UADMIN"), "/beer-admin readonly") {
+		t.Fatalf("admins must see admin commands")
+	}
+
+	// the default vocabulary produces parsable examples too
+	bot.vocab = nil
+	for _, ex := range bot.giftExamples() {
+		for _, m := range code.FindAllStringSubmatch(ex, -1) {
+			if bot.parseGift(strings.NewReplacer("@alex", "<@UALEX>", "@sam", "<@USAM>").Replace(m[1])) == nil {
+				t.Errorf("default example %q is not a gift", m[1])
+			}
+		}
+	}
+}
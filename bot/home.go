@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// homeReceived is how many recent gifts to the user the Home tab lists.
+const homeReceived = 5
+
+// handleAppHomeOpened publishes the user's Home tab when they open it.
+func (bot *MinimalSlackBot) handleAppHomeOpened(ctx context.Context, user, tab string) {
+	if tab != "home" || user == "" {
+		return
+	}
+	bot.homeMu.Lock()
+	if bot.homeUsers == nil {
+		bot.homeUsers = map[string]bool{}
+	}
+	bot.homeUsers[user] = true
+	bot.homeMu.Unlock()
+	bot.eventCounter.WithLabelValues("app_home", "opened").Inc()
+	bot.publishHome(ctx, user)
+}
+
+// refreshHomes republishes the Home tab of users whose beers changed. Only users
+// who opened their Home tab since the bot started are refreshed; everybody else
+// gets a fresh view when they open it.
+func (bot *MinimalSlackBot) refreshHomes(ctx context.Context, users ...string) {
+	bot.homeMu.Lock()
+	var stale []string
+	for _, u := range users {
+		if bot.homeUsers[u] {
+			stale = append(stale, u)
+		}
+	}
+	bot.homeMu.Unlock()
+	for _, u := range stale {
+		bot.publishHome(ctx, u)
+	}
+}
+
+// publishHome renders and publishes a user's Home tab.
+func (bot *MinimalSlackBot) publishHome(ctx context.Context, user string) {
+	view, err := bot.homeView(ctx, user, time.Now())
+	if err != nil {
+		bot.logger.Error().Err(err).Str("user", user).Msg("Failed to build Home tab")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	if _, err := bot.api.PublishView(user, view, ""); err != nil {
+		bot.logger.Warn().Err(err).Str("user", user).Msg("Failed to publish Home tab")
+		bot.errorCounter.WithLabelValues("message_error").Inc()
+	}
+}
+
+// homeView builds the Home tab: personal totals, streak, budget, recent gifts to
+// the user and this week's leaderboard.
+func (bot *MinimalSlackBot) homeView(ctx context.Context, user string, now time.Time) (slack.HomeTabViewRequest, error) {
+	summary, err := bot.beerMeSummary(ctx, user, now)
+	if err != nil {
+		return slack.HomeTabViewRequest{}, err
+	}
+	days, err := bot.store.GivingDays(ctx, user, 366)
+	if err != nil {
+		return slack.HomeTabViewRequest{}, err
+	}
+	received, err := bot.store.RecentReceived(ctx, user, homeReceived)
+	if err != nil {
+		return slack.HomeTabViewRequest{}, err
+	}
+	board, err := bot.buildLeaderboard(ctx, user, statsState{Period: "week", Size: defaultStatsPageSize})
+	if err != nil {
+		return slack.HomeTabViewRequest{}, err
+	}
+
+	md := func(t string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, t, false, false)
+	}
+	totals := func(label string, v [2]int) *slack.TextBlockObject {
+		return md(fmt.Sprintf("*%s*\nGiven %d · Received %d", label, v[0], v[1]))
+	}
+	left := "no daily limit"
+	if summary.remaining >= 0 {
+		left = beerCount(summary.remaining)
+	}
+	streak := givingStreak(days, now.In(summary.location))
+	streakText := "no streak yet — give a beer today!"
+	if streak > 0 {
+		streakText = fmt.Sprintf("🔥 %d day(s) in a row", streak)
+	}
+
+	var b strings.Builder
+	b.WriteString("*Recent beers for you*")
+	if len(received) == 0 {
+		b.WriteString("\nNothing yet — keep helping out!")
+	}
+	for _, r := range received {
+		line := fmt.Sprintf("%s %s", giverMention(r.Giver), beerCount(r.Count))
+		if r.Reason != "" {
+			line += " — " + r.Reason
+		}
+		if !r.Time.IsZero() {
+			line += " · " + r.Time.In(summary.location).Format("Jan 2")
+		}
+		if r.Permalink != "" {
+			line += fmt.Sprintf(" (<%s|view>)", r.Permalink)
+		}
+		b.WriteString("\n• " + line)
+	}
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "🍺 Your beers", false, false)),
+		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			totals("Today", summary.today),
+			totals("This week", summary.week),
+			totals("All time", summary.allTime),
+			md("*Left today*\n" + left),
+			md("*Giving streak*\n" + streakText),
+		}, nil),
+		slack.NewSectionBlock(md(b.String()), nil, nil),
+		slack.NewDividerBlock(),
+	}
+	blocks = append(blocks, board.blocks(false, "")...)
+	blocks = append(blocks, slack.NewContextBlock("", md(fmt.Sprintf(
+		"Updated %s · `/beer-stats` for other periods", now.In(summary.location).Format("Jan 2, 15:04")))))
+	return slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}}, nil
+}
+
+// givingStreak counts the consecutive days, up to today, on which beers were given.
+// days are YYYY-MM-DD, newest first. A streak that ended yesterday still counts
+// because it can be continued today.
+func givingStreak(days []string, today time.Time) int {
+	day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if len(days) > 0 && days[0] != day.Format("2006-01-02") {
+		day = day.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for _, d := range days {
+		if d != day.Format("2006-01-02") {
+			break
+		}
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+func TestGivingStreak(t *testing.T) {
+	today := time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		days []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"2024-05-15", "2024-05-14", "2024-05-13", "2024-05-10"}, 3},
+		{[]string{"2024-05-14", "2024-05-13"}, 2}, // not broken until today is over
+		{[]string{"2024-05-13", "2024-05-12"}, 0},
+	}
+	for _, c := range cases {
+		if got := givingStreak(c.days, today); got != c.want {
+			t.Errorf("%v: expected %d, got %d", c.days, c.want, got)
+		}
+	}
+}
+
+func TestAppHomeRefresh(t *testing.T) {
+	ctx := t.Context()
+	var published []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/views.publish") {
+			body, _ := io.ReadAll(r.Body)
+			var req struct {
+				UserID string          `json:"user_id"`
+				View   json.RawMessage `json:"view"`
+			}
+			_ = json.Unmarshal(body, &req)
+			published = append(published, req.UserID+" "+string(req.View))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1717691574.000100"}`))
+	}))
+	defer srv.Close()
+
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) {
+		b.api = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+		b.maxPerDay = 10
+	})
+
+	bot.handleAppHomeOpened(ctx, "UA", "messages")
+	if len(published) != 0 {
+		t.Fatalf("messages tab must not publish a view, got %v", published)
+	}
+	bot.handleAppHomeOpened(ctx, "UA", "home")
+	if len(published) != 1 || !strings.HasPrefix(published[0], "UA ") || !strings.Contains(published[0], `"type":"home"`) {
+		t.Fatalf("expected UA's Home tab, got %v", published)
+	}
+
+	// a gift to UA refreshes their Home tab; the giver never opened theirs
+	published = nil
+	bot.deliverGifts(ctx, giftRequest{dedupKey: "E1", giver: "UG", channel: "C1", ts: "1.1", eventTime: time.Now(), gifts: []recipientGift{{recipient: "UA", quantity: 1}}})
+	if len(published) != 1 || !strings.HasPrefix(published[0], "UA ") {
+		t.Fatalf("expected a refresh of UA only, got %v", published)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"github.com/slack-go/slack"
+)
+
+// handleInteraction routes Socket Mode interactive payloads (view submissions,
+// shortcuts and block actions). It returns the payload for the envelope ACK (nil
+// for a plain ACK) and an optional follow-up to run once the ACK has been sent;
+// anything slow or anything that opens a view belongs in the follow-up.
+func (bot *MinimalSlackBot) handleInteraction(ctx context.Context, cb slack.InteractionCallback) (interface{}, func()) {
+	if bot.traceEvents {
+		bot.logger.Debug().Str("interaction_type", string(cb.Type)).Str("callback_id", cb.CallbackID).Str("view_callback_id", cb.View.CallbackID).Msg("Interaction received")
+	}
+	switch cb.Type {
+	case slack.InteractionTypeViewSubmission:
+		return bot.routeViewSubmission(ctx, cb)
+	case slack.InteractionTypeMessageAction, slack.InteractionTypeShortcut:
+		if f := bot.routeShortcut(cb); f != nil {
+			return nil, f
+		}
+	case slack.InteractionTypeBlockActions:
+		var followUps []func()
+		for _, action := range cb.ActionCallback.BlockActions {
+			if f := bot.routeBlockAction(ctx, cb, action); f != nil {
+				followUps = append(followUps, f)
+			}
+		}
+		if len(followUps) > 0 {
+			return nil, func() {
+				for _, f := range followUps {
+					f()
+				}
+			}
+		}
+		return nil, nil
+	}
+	bot.logger.Debug().Str("interaction_type", string(cb.Type)).Str("callback_id", cb.CallbackID).Msg("Ignoring interaction")
+	return nil, nil
+}
+
+// routeViewSubmission dispatches modal submissions by the view's callback ID.
+func (bot *MinimalSlackBot) routeViewSubmission(ctx context.Context, cb slack.InteractionCallback) (interface{}, func()) {
+	switch cb.View.CallbackID {
+	case giveCallbackID:
+		resp, followUp := bot.handleGiveSubmission(ctx, cb)
+		if resp != nil {
+			return resp, nil // keep the modal open with field errors
+		}
+		return nil, followUp
+	}
+	bot.logger.Debug().Str("callback_id", cb.View.CallbackID).Msg("Ignoring view submission")
+	return nil, nil
+}
+
+// routeShortcut dispatches global and message shortcuts by callback ID.
+func (bot *MinimalSlackBot) routeShortcut(cb slack.InteractionCallback) func() {
+	switch cb.CallbackID {
+	case giveForMessage:
+		return func() { bot.handleGiveForMessage(cb) }
+	}
+	bot.logger.Debug().Str("callback_id", cb.CallbackID).Msg("Ignoring shortcut")
+	return nil
+}
+
+// routeBlockAction dispatches button and select actions by action ID. Inputs inside
+// modals do not dispatch actions; they are read on submission.
+func (bot *MinimalSlackBot) routeBlockAction(ctx context.Context, cb slack.InteractionCallback, action *slack.BlockAction) func() {
+	switch action.ActionID {
+	case undoActionID:
+		return func() { bot.handleUndoAction(ctx, cb, action) }
+	case statsPeriodAction, statsFromAction, statsToAction, statsPrevAction, statsNextAction, statsShareAction:
+		return func() { bot.handleStatsAction(ctx, cb, action) }
+	}
+	bot.logger.Debug().Str("action_id", action.ActionID).Msg("Ignoring block action")
+	return nil
+}
@@ -6,14 +6,17 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -21,20 +24,6 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-// Store interface defines the storage operations needed by the bot
-type Store interface {
-	CountGivenInDateRange(user string, start, end time.Time) (int, error)
-	CountReceivedInDateRange(user string, start, end time.Time) (int, error)
-	CountGivenOnDate(user string, date string) (int, error)
-	GetAllGivers() ([]string, error)
-	GetAllRecipients() ([]string, error)
-	TryMarkEventProcessed(eventID string, t time.Time) (bool, error)
-	AddBeer(giver string, recipient string, ts string, eventTime time.Time, count int) error
-	RecordBeerEventOutcome(eventID, giverID, recipientID string, quantity int, status string, t time.Time) error
-	TopGivers(start, end time.Time, limit int) ([][2]string, error)
-	TopReceivers(start, end time.Time, limit int) ([][2]string, error)
-}
-
 func parseLogLevel(levelStr string) zerolog.Level {
 	switch strings.ToLower(strings.TrimSpace(levelStr)) {
 	case "trace":
@@ -85,6 +74,38 @@ func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
 	logger := log.With().Str("component", "main").Logger()
+
+	if flag.Arg(0) == "migrate" {
+		// Ctrl-C rolls back the migration in progress
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		var m *storage.Migrator
+		switch driver := strings.ToLower(envOr("DB_DRIVER", "sqlite")); driver {
+		case "sqlite":
+			db, err := sql.Open("sqlite", envOr("DB_PATH", "/data/beerbot.db"))
+			if err != nil {
+				logger.Fatal().Err(err).Msg("Failed to open database")
+			}
+			defer db.Close()
+			m = storage.NewSQLiteMigrator(db)
+		case "postgres":
+			db, err := sql.Open(storage.PostgresDriver, os.Getenv("DATABASE_URL"))
+			if err != nil {
+				logger.Fatal().Err(err).Msg("Failed to open database")
+			}
+			defer db.Close()
+			m = storage.NewPostgresMigrator(db)
+		case "memory":
+			logger.Fatal().Msg("DB_DRIVER=memory has no schema to migrate")
+		default:
+			logger.Fatal().Str("db_driver", driver).Msg("DB_DRIVER must be sqlite or postgres")
+		}
+		if err := storage.RunMigrateCommand(ctx, m, flag.Args()[1:], os.Stdout); err != nil {
+			logger.Fatal().Err(err).Msg("Migration failed")
+		}
+		return
+	}
+
 	logger.Info().Msg("Starting minimal BeerBot...")
 
 	// Get configuration from environment (matching docker-compose variable names)
@@ -118,234 +139,55 @@ func main() {
 		}
 	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "/data/beerbot.db"
-	}
-
-	// Initialize database with comprehensive diagnostics
-	logger.Info().Str("db_path", dbPath).Msg("Initializing database")
-
-	// Check if DB path is absolute or relative
-	logger.Debug().
-		Str("db_path", dbPath).
-		Bool("is_absolute", filepath.IsAbs(dbPath)).
-		Msg("Database path analysis")
-
-	// Check parent directory existence and permissions
-	dbDir := filepath.Dir(dbPath)
-	if dirInfo, err := os.Stat(dbDir); err != nil {
-		logger.Error().
-			Err(err).
-			Str("db_dir", dbDir).
-			Msg("Database directory does not exist or is not accessible")
-		logger.Fatal().
-			Str("db_dir", dbDir).
-			Msg("Cannot proceed - database directory must exist and be writable")
-	} else {
-		logger.Debug().
-			Str("db_dir", dbDir).
-			Str("permissions", dirInfo.Mode().String()).
-			Bool("is_dir", dirInfo.IsDir()).
-			Msg("Database directory info")
-
-		// Check if directory is writable
-		testFile := filepath.Join(dbDir, ".write_test")
-		if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-			logger.Error().
-				Err(err).
-				Str("db_dir", dbDir).
-				Str("permissions", dirInfo.Mode().String()).
-				Msg("Database directory is not writable")
-			logger.Fatal().
-				Str("db_dir", dbDir).
-				Msg("Cannot proceed - database directory must be writable")
-		} else {
-			os.Remove(testFile)
-			logger.Debug().
-				Str("db_dir", dbDir).
-				Msg("Database directory is writable")
-		}
-
-		// Additional filesystem check: try to write and then modify the test file
-		// This detects read-only filesystem mounts even when permissions look correct
-		fsTestFile := filepath.Join(dbDir, ".fs_write_test")
-		if err := os.WriteFile(fsTestFile, []byte("initial"), 0644); err != nil {
-			logger.Error().
-				Err(err).
-				Str("db_dir", dbDir).
-				Msg("Filesystem appears to be read-only (write failed)")
-			logger.Fatal().
-				Str("db_dir", dbDir).
-				Msg("Cannot proceed - filesystem is mounted read-only")
-		}
-
-		// Try to modify the file to ensure filesystem is truly writable
-		if err := os.WriteFile(fsTestFile, []byte("modified"), 0644); err != nil {
-			os.Remove(fsTestFile)
-			logger.Error().
-				Err(err).
-				Str("db_dir", dbDir).
-				Msg("Filesystem appears to be read-only (modification failed)")
-			logger.Fatal().
-				Str("db_dir", dbDir).
-				Msg("Cannot proceed - filesystem is mounted read-only or has restrictions")
-		}
-		os.Remove(fsTestFile)
-		logger.Debug().
-			Str("db_dir", dbDir).
-			Msg("Filesystem is fully writable (create and modify successful)")
-	}
-
-	// Check if database file exists and log its permissions
-	if fileInfo, err := os.Stat(dbPath); err == nil {
-		logger.Debug().
-			Str("db_path", dbPath).
-			Str("permissions", fileInfo.Mode().String()).
-			Int64("size_bytes", fileInfo.Size()).
-			Msg("Existing database file found")
-
-		// Check if file is writable by owner (user permission bit)
-		mode := fileInfo.Mode()
-		isWritable := mode&0200 != 0 // Owner write permission
-
-		// Check if permissions meet minimum requirement: rw-rw-rw- (0666)
-		requiredPerms := os.FileMode(0666)
-		currentPerms := mode.Perm()
-		hasMinimumPerms := (currentPerms & requiredPerms) == requiredPerms
-
-		if !isWritable || !hasMinimumPerms {
-			logger.Warn().
-				Str("db_path", dbPath).
-				Str("current_permissions", mode.String()).
-				Str("required_minimum", requiredPerms.String()).
-				Bool("owner_writable", isWritable).
-				Bool("has_minimum_perms", hasMinimumPerms).
-				Msg("Database file permissions insufficient - attempting to fix")
-
-			// Set to rw-rw-rw- (0666)
-			newMode := requiredPerms
-			if chmodErr := os.Chmod(dbPath, newMode); chmodErr != nil {
-				logger.Error().
-					Err(chmodErr).
-					Str("db_path", dbPath).
-					Str("current_permissions", mode.String()).
-					Str("attempted_permissions", newMode.String()).
-					Msg("Failed to fix database file permissions")
-				logger.Fatal().
-					Str("db_path", dbPath).
-					Str("permissions", mode.String()).
-					Str("required", requiredPerms.String()).
-					Msg("Database file must have at least rw-rw-rw- (0666) permissions")
-			} else {
-				logger.Info().
-					Str("db_path", dbPath).
-					Str("old_permissions", mode.String()).
-					Str("new_permissions", newMode.String()).
-					Msg("Successfully updated database file permissions to rw-rw-rw-")
-			}
-		} else {
-			logger.Debug().
-				Str("db_path", dbPath).
-				Str("permissions", mode.String()).
-				Msg("Database file permissions are sufficient (minimum rw-rw-rw-)")
-		}
-
-		// Test if we can actually open the file for writing (even with correct permissions, filesystem might be read-only)
-		testWrite, openErr := os.OpenFile(dbPath, os.O_WRONLY|os.O_APPEND, 0644)
-		if openErr != nil {
-			logger.Error().
-				Err(openErr).
-				Str("db_path", dbPath).
-				Str("permissions", mode.String()).
-				Msg("Cannot open database file for writing - filesystem may be read-only")
-			logger.Fatal().
-				Str("db_path", dbPath).
-				Msg("Database file cannot be opened for writing despite correct permissions")
-		}
-		testWrite.Close()
-		logger.Debug().
-			Str("db_path", dbPath).
-			Msg("Database file can be opened for writing")
-	} else if os.IsNotExist(err) {
-		logger.Debug().
-			Str("db_path", dbPath).
-			Msg("Database file does not exist - will be created")
-	} else {
-		logger.Warn().
-			Err(err).
-			Str("db_path", dbPath).
-			Msg("Could not stat database file")
-	}
-
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		logger.Fatal().
-			Err(err).
-			Str("db_path", dbPath).
-			Msg("Failed to open database")
-	}
-	defer db.Close()
-
-	// Test database connection
-	if err := db.Ping(); err != nil {
-		logger.Error().
-			Err(err).
-			Str("db_path", dbPath).
-			Msg("Database ping failed - connection error")
-
-		// Additional diagnostics after ping failure
-		if fileInfo, statErr := os.Stat(dbPath); statErr == nil {
-			logger.Debug().
-				Str("db_path", dbPath).
-				Str("permissions", fileInfo.Mode().String()).
-				Int64("size_bytes", fileInfo.Size()).
-				Msg("Database file after failed ping")
+	shutdownTimeout := 5 * time.Second
+	if v := strings.TrimSpace(os.Getenv("SHUTDOWN_TIMEOUT")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			shutdownTimeout = d
 		}
-
-		logger.Fatal().
-			Err(err).
-			Str("db_path", dbPath).
-			Msg("Failed to ping database")
 	}
 
-	logger.Debug().
-		Str("db_path", dbPath).
-		Msg("Database connection successful")
-
-	// Initialize store
-	store, err := NewSQLiteStore(db)
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Str("db_path", dbPath).
-			Msg("Store initialization failed during migration")
-
-		// Check file permissions after migration failure
-		if fileInfo, statErr := os.Stat(dbPath); statErr == nil {
-			logger.Debug().
-				Str("db_path", dbPath).
-				Str("permissions", fileInfo.Mode().String()).
-				Int64("size_bytes", fileInfo.Size()).
-				Msg("Database file after migration failure")
-		}
+	// Parent of all storage work, including startup migrations; canceled once
+	// SHUTDOWN_TIMEOUT has passed after a shutdown signal
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	shutdownCh := make(chan os.Signal, 1)
+	go func() {
+		sig := <-sigCh
+		time.AfterFunc(shutdownTimeout, cancelBase)
+		shutdownCh <- sig
+	}()
 
-		logger.Fatal().
-			Err(err).
-			Str("db_path", dbPath).
-			Msg("Failed to initialize store")
+	var store storage.Store
+	switch driver := strings.ToLower(envOr("DB_DRIVER", "sqlite")); driver {
+	case "sqlite":
+		s, db := openSQLiteStore(baseCtx, logger, envOr("DB_PATH", "/data/beerbot.db"))
+		defer db.Close()
+		store = s
+	case "postgres":
+		s, db := openPostgresStore(baseCtx, logger, os.Getenv("DATABASE_URL"))
+		defer db.Close()
+		store = s
+	case "memory":
+		logger.Warn().Msg("DB_DRIVER=memory: all beers are lost when the bot stops; use it for demos only")
+		store = storage.NewMemoryStore()
+	default:
+		logger.Fatal().Str("db_driver", driver).Msg("DB_DRIVER must be sqlite, postgres or memory")
 	}
 
-	logger.Debug().
-		Str("db_path", dbPath).
-		Msg("Store initialized successfully")
-
 	// Get API token for authentication
 	apiToken := os.Getenv("API_TOKEN")
 	if apiToken == "" {
 		apiToken = "my-secret-token" // fallback for development
 	}
 
+	// Storage deadline of each API request and Slack event, e.g. REQUEST_TIMEOUT=5s
+	requestTimeout, err := requestTimeoutFromEnv()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid configuration")
+	}
+
 	// HTTP server (API + metrics + health) - START THIS FIRST before Slack connection
 	// This ensures the API is always available even if Slack is down
 	serverPort := os.Getenv("SERVER_PORT")
@@ -410,7 +252,7 @@ func main() {
 			http.Error(w, "invalid or missing date range: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		c, err := store.CountGivenInDateRange(user, start, end)
+		c, err := store.CountGivenInDateRange(r.Context(), user, start, end)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -435,7 +277,7 @@ func main() {
 			http.Error(w, "invalid or missing date range: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		c, err := store.CountReceivedInDateRange(user, start, end)
+		c, err := store.CountReceivedInDateRange(r.Context(), user, start, end)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -468,7 +310,7 @@ func main() {
 		}
 		
 		// Try to get user info from Slack
-		user, err := slackClient.GetUserInfo(userID)
+		user, err := slackClient.GetUserInfoContext(r.Context(), userID)
 		if err != nil {
 			// On error, return user ID as fallback instead of 500 error
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{
@@ -486,7 +328,7 @@ func main() {
 	})
 
 	giversHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		list, err := store.GetAllGivers()
+		list, err := store.GetAllGivers(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -496,7 +338,7 @@ func main() {
 	})
 
 	recipientsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		list, err := store.GetAllRecipients()
+		list, err := store.GetAllRecipients(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -505,13 +347,44 @@ func main() {
 		_ = json.NewEncoder(w).Encode(list)
 	})
 
-	mux.Handle("/api/given", authMiddleware(apiToken, givenHandler))
-	mux.Handle("/api/received", authMiddleware(apiToken, receivedHandler))
-	mux.Handle("/api/user", authMiddleware(apiToken, userHandler))
-	mux.Handle("/api/givers", authMiddleware(apiToken, giversHandler))
-	mux.Handle("/api/recipients", authMiddleware(apiToken, recipientsHandler))
+	kudosHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 500 {
+				http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		list, err := store.RecentBeers(r.Context(), r.URL.Query().Get("user"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []storage.BeerRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	})
 
-	server := &http.Server{Addr: ":" + serverPort, Handler: mux}
+	// API requests get REQUEST_TIMEOUT as the deadline of their context
+	api := func(h http.Handler) http.Handler {
+		return http.TimeoutHandler(authMiddleware(apiToken, h), requestTimeout, "request timed out")
+	}
+	mux.Handle("/api/given", api(givenHandler))
+	mux.Handle("/api/received", api(receivedHandler))
+	mux.Handle("/api/user", api(userHandler))
+	mux.Handle("/api/givers", api(giversHandler))
+	mux.Handle("/api/recipients", api(recipientsHandler))
+	mux.Handle("/api/kudos", api(kudosHandler))
+
+	server := &http.Server{
+		Addr:        ":" + serverPort,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
 	go func() {
 		logger.Info().
 			Str("port", serverPort).
@@ -522,7 +395,7 @@ func main() {
 	}()
 
 	// Create minimal Slack bot (non-fatal if fails)
-	bot, err := NewMinimalSlackBot(botToken, appToken, store, logger)
+	bot, err := NewMinimalSlackBot(baseCtx, botToken, appToken, store, logger)
 	if err != nil {
 		logger.Warn().Err(err).Msg("Failed to create Slack bot - will continue without Slack functionality")
 		slackConnected = false
@@ -543,17 +416,15 @@ func main() {
 	if slackConnected && bot != nil {
 		go func() {
 			logger.Info().Msg("Starting minimal Slack bot with Socket Mode")
-			botErrCh <- bot.Start()
+			botErrCh <- bot.Start(baseCtx)
 		}()
 	} else {
 		logger.Warn().Msg("Slack bot not started due to connection issues - API server running in degraded mode")
 	}
 
 	// Graceful shutdown
-	sigCh := make(chan os.Signal, 2)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	select {
-	case sig := <-sigCh:
+	case sig := <-shutdownCh:
 		logger.Info().Str("signal", sig.String()).Msg("Shutdown requested")
 	case err := <-botErrCh:
 		if err != nil {
@@ -561,18 +432,19 @@ func main() {
 		}
 	}
 
-	shutdownTimeout := 5 * time.Second
-	if v := strings.TrimSpace(os.Getenv("SHUTDOWN_TIMEOUT")); v != "" {
-		if d, err := time.ParseDuration(v); err == nil && d > 0 {
-			shutdownTimeout = d
-		}
-	}
+	// In-flight events and API requests get SHUTDOWN_TIMEOUT to finish; storage
+	// work still running after that is canceled before the database is closed
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
-	bot.Stop()
+	if bot != nil {
+		if err := bot.Stop(shutdownCtx); err != nil {
+			logger.Warn().Err(err).Msg("Slack bot did not finish in-flight work in time")
+		}
+	}
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Warn().Err(err).Msg("HTTP server shutdown error")
 	}
+	cancelBase()
 	logger.Info().Msg("Shutdown complete")
 }
 
@@ -625,3 +497,246 @@ func authMiddleware(apiToken string, next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// openSQLiteStore checks that the database directory and file are writable, opens
+// the SQLite database at dbPath and applies pending migrations. It exits on failure.
+func openSQLiteStore(ctx context.Context, logger zerolog.Logger, dbPath string) (*storage.SQLiteStore, *sql.DB) {
+	// Initialize database with comprehensive diagnostics
+	logger.Info().Str("db_path", dbPath).Msg("Initializing database")
+
+	// Check if DB path is absolute or relative
+	logger.Debug().
+		Str("db_path", dbPath).
+		Bool("is_absolute", filepath.IsAbs(dbPath)).
+		Msg("Database path analysis")
+
+	// Check parent directory existence and permissions
+	dbDir := filepath.Dir(dbPath)
+	if dirInfo, err := os.Stat(dbDir); err != nil {
+		logger.Error().
+			Err(err).
+			Str("db_dir", dbDir).
+			Msg("Database directory does not exist or is not accessible")
+		logger.Fatal().
+			Str("db_dir", dbDir).
+			Msg("Cannot proceed - database directory must exist and be writable")
+	} else {
+		logger.Debug().
+			Str("db_dir", dbDir).
+			Str("permissions", dirInfo.Mode().String()).
+			Bool("is_dir", dirInfo.IsDir()).
+			Msg("Database directory info")
+
+		// Check if directory is writable
+		testFile := filepath.Join(dbDir, ".write_test")
+		if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+			logger.Error().
+				Err(err).
+				Str("db_dir", dbDir).
+				Str("permissions", dirInfo.Mode().String()).
+				Msg("Database directory is not writable")
+			logger.Fatal().
+				Str("db_dir", dbDir).
+				Msg("Cannot proceed - database directory must be writable")
+		} else {
+			os.Remove(testFile)
+			logger.Debug().
+				Str("db_dir", dbDir).
+				Msg("Database directory is writable")
+		}
+
+		// Additional filesystem check: try to write and then modify the test file
+		// This detects read-only filesystem mounts even when permissions look correct
+		fsTestFile := filepath.Join(dbDir, ".fs_write_test")
+		if err := os.WriteFile(fsTestFile, []byte("initial"), 0644); err != nil {
+			logger.Error().
+				Err(err).
+				Str("db_dir", dbDir).
+				Msg("Filesystem appears to be read-only (write failed)")
+			logger.Fatal().
+				Str("db_dir", dbDir).
+				Msg("Cannot proceed - filesystem is mounted read-only")
+		}
+
+		// Try to modify the file to ensure filesystem is truly writable
+		if err := os.WriteFile(fsTestFile, []byte("modified"), 0644); err != nil {
+			os.Remove(fsTestFile)
+			logger.Error().
+				Err(err).
+				Str("db_dir", dbDir).
+				Msg("Filesystem appears to be read-only (modification failed)")
+			logger.Fatal().
+				Str("db_dir", dbDir).
+				Msg("Cannot proceed - filesystem is mounted read-only or has restrictions")
+		}
+		os.Remove(fsTestFile)
+		logger.Debug().
+			Str("db_dir", dbDir).
+			Msg("Filesystem is fully writable (create and modify successful)")
+	}
+
+	// Check if database file exists and log its permissions
+	if fileInfo, err := os.Stat(dbPath); err == nil {
+		logger.Debug().
+			Str("db_path", dbPath).
+			Str("permissions", fileInfo.Mode().String()).
+			Int64("size_bytes", fileInfo.Size()).
+			Msg("Existing database file found")
+
+		// Check if file is writable by owner (user permission bit)
+		mode := fileInfo.Mode()
+		isWritable := mode&0200 != 0 // Owner write permission
+
+		// Check if permissions meet minimum requirement: rw-rw-rw- (0666)
+		requiredPerms := os.FileMode(0666)
+		currentPerms := mode.Perm()
+		hasMinimumPerms := (currentPerms & requiredPerms) == requiredPerms
+
+		if !isWritable || !hasMinimumPerms {
+			logger.Warn().
+				Str("db_path", dbPath).
+				Str("current_permissions", mode.String()).
+				Str("required_minimum", requiredPerms.String()).
+				Bool("owner_writable", isWritable).
+				Bool("has_minimum_perms", hasMinimumPerms).
+				Msg("Database file permissions insufficient - attempting to fix")
+
+			// Set to rw-rw-rw- (0666)
+			newMode := requiredPerms
+			if chmodErr := os.Chmod(dbPath, newMode); chmodErr != nil {
+				logger.Error().
+					Err(chmodErr).
+					Str("db_path", dbPath).
+					Str("current_permissions", mode.String()).
+					Str("attempted_permissions", newMode.String()).
+					Msg("Failed to fix database file permissions")
+				logger.Fatal().
+					Str("db_path", dbPath).
+					Str("permissions", mode.String()).
+					Str("required", requiredPerms.String()).
+					Msg("Database file must have at least rw-rw-rw- (0666) permissions")
+			} else {
+				logger.Info().
+					Str("db_path", dbPath).
+					Str("old_permissions", mode.String()).
+					Str("new_permissions", newMode.String()).
+					Msg("Successfully updated database file permissions to rw-rw-rw-")
+			}
+		} else {
+			logger.Debug().
+				Str("db_path", dbPath).
+				Str("permissions", mode.String()).
+				Msg("Database file permissions are sufficient (minimum rw-rw-rw-)")
+		}
+
+		// Test if we can actually open the file for writing (even with correct permissions, filesystem might be read-only)
+		testWrite, openErr := os.OpenFile(dbPath, os.O_WRONLY|os.O_APPEND, 0644)
+		if openErr != nil {
+			logger.Error().
+				Err(openErr).
+				Str("db_path", dbPath).
+				Str("permissions", mode.String()).
+				Msg("Cannot open database file for writing - filesystem may be read-only")
+			logger.Fatal().
+				Str("db_path", dbPath).
+				Msg("Database file cannot be opened for writing despite correct permissions")
+		}
+		testWrite.Close()
+		logger.Debug().
+			Str("db_path", dbPath).
+			Msg("Database file can be opened for writing")
+	} else if os.IsNotExist(err) {
+		logger.Debug().
+			Str("db_path", dbPath).
+			Msg("Database file does not exist - will be created")
+	} else {
+		logger.Warn().
+			Err(err).
+			Str("db_path", dbPath).
+			Msg("Could not stat database file")
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		logger.Fatal().
+			Err(err).
+			Str("db_path", dbPath).
+			Msg("Failed to open database")
+	}
+
+	// Test database connection
+	if err := db.PingContext(ctx); err != nil {
+		logger.Error().
+			Err(err).
+			Str("db_path", dbPath).
+			Msg("Database ping failed - connection error")
+
+		// Additional diagnostics after ping failure
+		if fileInfo, statErr := os.Stat(dbPath); statErr == nil {
+			logger.Debug().
+				Str("db_path", dbPath).
+				Str("permissions", fileInfo.Mode().String()).
+				Int64("size_bytes", fileInfo.Size()).
+				Msg("Database file after failed ping")
+		}
+
+		logger.Fatal().
+			Err(err).
+			Str("db_path", dbPath).
+			Msg("Failed to ping database")
+	}
+
+	logger.Debug().
+		Str("db_path", dbPath).
+		Msg("Database connection successful")
+
+	// Initialize store
+	store, err := storage.NewSQLiteStore(ctx, db)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("db_path", dbPath).
+			Msg("Store initialization failed during migration")
+
+		// Check file permissions after migration failure
+		if fileInfo, statErr := os.Stat(dbPath); statErr == nil {
+			logger.Debug().
+				Str("db_path", dbPath).
+				Str("permissions", fileInfo.Mode().String()).
+				Int64("size_bytes", fileInfo.Size()).
+				Msg("Database file after migration failure")
+		}
+
+		logger.Fatal().
+			Err(err).
+			Str("db_path", dbPath).
+			Msg("Failed to initialize store")
+	}
+
+	logger.Debug().
+		Str("db_path", dbPath).
+		Msg("Store initialized successfully")
+	return store, db
+}
+
+// openPostgresStore connects to PostgreSQL (DATABASE_URL) and applies pending
+// migrations. It exits on failure.
+func openPostgresStore(ctx context.Context, logger zerolog.Logger, dsn string) (*storage.PostgresStore, *sql.DB) {
+	if dsn == "" {
+		logger.Fatal().Msg("DATABASE_URL is required when DB_DRIVER=postgres")
+	}
+	logger.Info().Msg("Connecting to PostgreSQL")
+	db, err := sql.Open(storage.PostgresDriver, dsn)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to open database")
+	}
+	if err := db.PingContext(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to ping database")
+	}
+	store, err := storage.NewPostgresStore(ctx, db)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize store")
+	}
+	logger.Debug().Msg("Store initialized successfully")
+	return store, db
+}
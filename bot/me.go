@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+// beerMeHistory is how many recent gifts /beer-me lists.
+const beerMeHistory = 10
+
+// beerMeSummary is the data behind /beer-me.
+type beerMeSummary struct {
+	user                 string
+	today, week, allTime [2]int // given, received
+	remaining            int    // -1 when there is no daily limit
+	givesTo, getsFrom    [][2]string
+	recent               []storage.BeerRecord
+	location             *time.Location
+}
+
+// handleBeerMe implements /beer-me: an ephemeral personal summary.
+func (bot *MinimalSlackBot) handleBeerMe(ctx context.Context, cmd slack.SlashCommand) {
+	summary, err := bot.beerMeSummary(ctx, cmd.UserID, time.Now())
+	if err != nil {
+		bot.logger.Error().Err(err).Str("user", cmd.UserID).Msg("Failed to build /beer-me summary")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		bot.postEphemeral(cmd.ChannelID, cmd.UserID, "Error generating your beer summary.")
+		return
+	}
+	_, err = bot.api.PostEphemeral(cmd.ChannelID, cmd.UserID,
+		slack.MsgOptionText(fmt.Sprintf("You gave %d and received %d beers today.", summary.today[0], summary.today[1]), false),
+		slack.MsgOptionBlocks(beerMeBlocks(summary)...),
+	)
+	if err != nil {
+		bot.logger.Debug().Err(err).Msg("Failed to post ephemeral message")
+	}
+}
+
+// beerMeSummary collects a user's totals, budget, top partners and recent gifts.
+// Days and weeks (starting Monday) are calendar periods in the user's time zone.
+func (bot *MinimalSlackBot) beerMeSummary(ctx context.Context, user string, now time.Time) (*beerMeSummary, error) {
+	loc := bot.userLocation(user)
+	today := now.In(loc)
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	s := &beerMeSummary{user: user, location: loc}
+	var err error
+	periods := []struct {
+		into  *[2]int
+		start time.Time
+	}{{&s.today, today}, {&s.week, weekStart}, {&s.allTime, time.Time{}}}
+	for _, p := range periods {
+		if p.into[0], p.into[1], err = bot.store.UserTotals(ctx, user, p.start, today); err != nil {
+			return nil, err
+		}
+	}
+	if s.remaining, err = bot.remainingBudget(ctx, user, today, 0); err != nil {
+		return nil, err
+	}
+	if s.givesTo, err = bot.store.TopRecipientsOf(ctx, user, 3); err != nil {
+		return nil, err
+	}
+	if s.getsFrom, err = bot.store.TopGiversTo(ctx, user, 3); err != nil {
+		return nil, err
+	}
+	if s.recent, err = bot.store.RecentBeers(ctx, user, beerMeHistory); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// beerMeBlocks renders the /beer-me summary as Block Kit.
+func beerMeBlocks(s *beerMeSummary) []slack.Block {
+	md := func(t string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, t, false, false)
+	}
+	totals := func(label string, v [2]int) *slack.TextBlockObject {
+		return md(fmt.Sprintf("*%s*\nGiven %d · Received %d", label, v[0], v[1]))
+	}
+	left := "no daily limit"
+	if s.remaining >= 0 {
+		left = beerCount(s.remaining)
+	}
+	partners := func(rows [][2]string) string {
+		if len(rows) == 0 {
+			return "(none yet)"
+		}
+		items := make([]string, 0, len(rows))
+		for _, r := range rows {
+			items = append(items, fmt.Sprintf("<@%s> (%s)", r[0], r[1]))
+		}
+		return strings.Join(items, ", ")
+	}
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "🍺 Your beers", false, false)),
+		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			totals("Today", s.today),
+			totals("This week", s.week),
+			totals("All time", s.allTime),
+			md("*Left today*\n" + left),
+		}, nil),
+		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			md("*You give most to*\n" + partners(s.givesTo)),
+			md("*You get most from*\n" + partners(s.getsFrom)),
+		}, nil),
+		slack.NewDividerBlock(),
+	}
+
+	var b strings.Builder
+	b.WriteString("*Recent gifts*")
+	if len(s.recent) == 0 {
+		b.WriteString("\nNo gifts yet — thank someone with `@someone 🍺`!")
+	}
+	for _, r := range s.recent {
+		line := fmt.Sprintf("%s → <@%s> %s", giverMention(r.Giver), r.Recipient, beerCount(r.Count))
+		if r.Reason != "" {
+			line += " — " + r.Reason
+		}
+		if !r.Time.IsZero() {
+			line += " · " + r.Time.In(s.location).Format("Jan 2")
+		}
+		if r.Permalink != "" {
+			line += fmt.Sprintf(" (<%s|view>)", r.Permalink)
+		}
+		b.WriteString("\n• " + line)
+	}
+	blocks = append(blocks, slack.NewSectionBlock(md(b.String()), nil, nil))
+	return blocks
+}
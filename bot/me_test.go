@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+)
+
+func TestBeerMeSummary(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "me.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store, err := storage.NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	// Wednesday; the week started on Monday the 3rd
+	now := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+	gifts := []struct {
+		giver, recipient, ts string
+		at                   time.Time
+		count                int
+		reason               string
+	}{
+		{"UME", "UA", "1.1", now, 2, "for the review"},
+		{"UME", "UB", "1.2", now.AddDate(0, 0, -1), 1, ""},
+		{"UA", "UME", "1.3", now.AddDate(0, 0, -2), 3, "for lunch"},
+		{"UB", "UME", "1.4", now.AddDate(0, 0, -10), 1, ""},
+		{"UME", "UA", "1.5", now.AddDate(0, 0, -30), 1, ""},
+	}
+	for _, g := range gifts {
+		if err := store.AddBeer(ctx, g.giver, g.recipient, g.ts, g.at, g.count, storage.BeerNote{Reason: g.reason}); err != nil {
+			t.Fatalf("addbeer: %v", err)
+		}
+	}
+
+	bot := &MinimalSlackBot{store: store, maxPerDay: 10}
+	s, err := bot.beerMeSummary(ctx, "UME", now)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if s.today != [2]int{2, 0} || s.week != [2]int{3, 3} || s.allTime != [2]int{4, 4} {
+		t.Fatalf("unexpected totals today=%v week=%v all=%v", s.today, s.week, s.allTime)
+	}
+	if s.remaining != 8 {
+		t.Fatalf("expected 8 beers left today, got %d", s.remaining)
+	}
+	if len(s.givesTo) != 2 || s.givesTo[0] != [2]string{"UA", "3"} {
+		t.Fatalf("unexpected top recipients %v", s.givesTo)
+	}
+	if len(s.getsFrom) != 2 || s.getsFrom[0] != [2]string{"UA", "3"} {
+		t.Fatalf("unexpected top givers %v", s.getsFrom)
+	}
+	if len(s.recent) != 5 || s.recent[0].Reason != "for the review" {
+		t.Fatalf("unexpected recent gifts %+v", s.recent)
+	}
+
+	var raw strings.Builder
+	enc := json.NewEncoder(&raw)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(beerMeBlocks(s)); err != nil {
+		t.Fatalf("marshal blocks: %v", err)
+	}
+	for _, want := range []string{"Left today", "8 beers", "<@UME> → <@UA> 2 beers — for the review · Jun 5"} {
+		if !strings.Contains(raw.String(), want) {
+			t.Fatalf("expected %q in blocks: %s", want, raw.String())
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+// Callback, block and action IDs of the /beer-give modal and the message shortcut.
+const (
+	giveCallbackID  = "beer_give"
+	giveForMessage  = "beer_give_message" // message shortcut
+	giveRecipients  = "recipients"
+	giveQuantity    = "quantity"
+	giveReason      = "reason"
+	giveChannel     = "channel"
+	maxGiveReason   = 300
+	maxGiveQuantity = 100 // Slack limits static selects to 100 options
+)
+
+// handleBeerGive opens the /beer-give modal; the command text may already name
+// recipients and a reason ("/beer-give @anna 2 for the review").
+func (bot *MinimalSlackBot) handleBeerGive(cmd slack.SlashCommand) {
+	prefill := giveModalInput{channel: cmd.ChannelID, quantity: 1}
+	if gift := bot.parseGift(cmd.Text); gift != nil {
+		for _, g := range gift.Recipients {
+			prefill.recipients = append(prefill.recipients, g.recipient)
+		}
+		prefill.quantity = gift.Recipients[0].quantity
+		prefill.reason = gift.Reason
+	} else {
+		for _, tok := range tokenize(cmd.Text, bot.vocabulary()) {
+			if tok.kind == tokMention {
+				prefill.recipients = append(prefill.recipients, tok.user)
+			}
+		}
+	}
+	bot.openGiveModal(cmd.TriggerID, cmd.UserID, prefill)
+}
+
+// giveModalInput is the (pre)filled content of the /beer-give modal.
+type giveModalInput struct {
+	recipients []string
+	quantity   int
+	reason     string
+	channel    string // where the gift is announced
+	source     string // "channel/ts" of the message a shortcut gift thanks for (private metadata)
+}
+
+// openGiveModal opens the gift modal for a trigger. Failures are reported to the user
+// ephemerally when the invoking channel is known.
+func (bot *MinimalSlackBot) openGiveModal(triggerID, user string, in giveModalInput) {
+	if _, err := bot.api.OpenView(triggerID, bot.giveModal(in)); err != nil {
+		bot.logger.Error().Err(err).Str("user", user).Msg("Failed to open beer modal")
+		bot.errorCounter.WithLabelValues("message_error").Inc()
+		bot.postEphemeral(in.channel, user, "⚠️ Could not open the beer dialog, please try again.")
+	}
+}
+
+// giveModal builds the Block Kit modal for giving beers.
+func (bot *MinimalSlackBot) giveModal(in giveModalInput) slack.ModalViewRequest {
+	text := func(s string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.PlainTextType, s, false, false)
+	}
+
+	users := slack.NewOptionsMultiSelectBlockElement(slack.MultiOptTypeUser, text("Who gets a beer?"), giveRecipients)
+	users.InitialUsers = in.recipients
+
+	limit := bot.maxGift
+	if limit < 1 || limit > maxGiveQuantity {
+		limit = maxGiveQuantity
+	}
+	var options []*slack.OptionBlockObject
+	for i := 1; i <= limit; i++ {
+		options = append(options, slack.NewOptionBlockObject(strconv.Itoa(i), text(beerCount(i)), nil))
+	}
+	quantity := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, text("How many?"), giveQuantity, options...)
+	q := min(max(in.quantity, 1), limit)
+	quantity.InitialOption = options[q-1]
+
+	reason := slack.NewPlainTextInputBlockElement(text("for fixing the prod outage"), giveReason)
+	reason.InitialValue = in.reason
+	reason.MaxLength = maxGiveReason
+
+	channel := slack.NewOptionsSelectBlockElement(slack.OptTypeConversations, text("Announce in"), giveChannel)
+	channel.InitialConversation = in.channel
+	channel.DefaultToCurrentConversation = in.channel == ""
+	channel.Filter = &slack.SelectBlockElementFilter{Include: []string{"public", "private"}}
+
+	reasonBlock := slack.NewInputBlock(giveReason, text("Reason"), nil, reason)
+	reasonBlock.Optional = true
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      giveCallbackID,
+		PrivateMetadata: in.source,
+		Title:           text("Give beers"),
+		Submit:          text("Give"),
+		Close:           text("Cancel"),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewInputBlock(giveRecipients, text("Recipients"), nil, users),
+			slack.NewInputBlock(giveQuantity, text("Beers each"), nil, quantity),
+			reasonBlock,
+			slack.NewInputBlock(giveChannel, text("Announce in"), nil, channel),
+		}},
+	}
+}
+
+// giveSubmission reads the submitted values of the gift modal.
+func giveSubmission(view slack.View) giveModalInput {
+	values := view.State.Values
+	in := giveModalInput{
+		recipients: values[giveRecipients][giveRecipients].SelectedUsers,
+		reason:     strings.TrimSpace(values[giveReason][giveReason].Value),
+		channel:    values[giveChannel][giveChannel].SelectedConversation,
+		source:     view.PrivateMetadata,
+	}
+	in.quantity, _ = strconv.Atoi(values[giveQuantity][giveQuantity].SelectedOption.Value)
+	return in
+}
+
+// handleGiveSubmission validates a gift modal submission. Problems the giver can fix
+// are returned as inline field errors (the modal stays open); otherwise it returns a
+// follow-up that delivers the gifts after the submission has been acknowledged.
+func (bot *MinimalSlackBot) handleGiveSubmission(ctx context.Context, cb slack.InteractionCallback) (*slack.ViewSubmissionResponse, func()) {
+	giver := cb.User.ID
+	in := giveSubmission(cb.View)
+
+	errs := map[string]string{}
+	if len(in.recipients) == 0 {
+		errs[giveRecipients] = "Pick at least one person."
+	}
+	for _, r := range in.recipients {
+		if r == giver {
+			errs[giveRecipients] = "You can't give beers to yourself."
+		}
+	}
+	if in.quantity < 1 {
+		errs[giveQuantity] = "Pick how many beers to give."
+	}
+	if in.channel == "" {
+		errs[giveChannel] = "Pick a channel to announce the gift in."
+	} else if !bot.channelAllowed(in.channel, "") {
+		errs[giveChannel] = "Beers can't be given in this channel."
+	}
+	now := time.Now().In(bot.userLocation(giver))
+	if len(errs) == 0 {
+		remaining, err := bot.remainingBudget(ctx, giver, now, 0)
+		switch {
+		case err != nil:
+			bot.logger.Error().Err(err).Str("giver", giver).Msg("Failed to check daily beer budget")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			errs[giveQuantity] = "Could not check your daily limit, please try again."
+		case remaining == 0:
+			errs[giveQuantity] = fmt.Sprintf("Daily limit of %d beers reached.", bot.maxPerDay)
+		}
+	}
+	if len(errs) > 0 {
+		bot.eventCounter.WithLabelValues("beer_modal", "invalid").Inc()
+		return slack.NewErrorsViewSubmissionResponse(errs), nil
+	}
+
+	return nil, func() {
+		bot.eventCounter.WithLabelValues("beer_modal", "submitted").Inc()
+		// Modal gifts have no message of their own: they are keyed by the submission time
+		ts := formatSlackTS(now)
+		dedupKey := "view_submission:" + cb.View.ID
+		if !bot.markEventProcessed(ctx, dedupKey, giver, now) {
+			return
+		}
+		note := storage.BeerNote{Reason: in.reason, ChannelID: in.channel}
+		if channel, msgTS, ok := strings.Cut(in.source, "/"); ok {
+			note.Permalink = bot.permalink(channel, msgTS)
+		}
+		gifts := make([]recipientGift, 0, len(in.recipients))
+		for _, r := range in.recipients {
+			gifts = append(gifts, recipientGift{recipient: r, quantity: in.quantity})
+		}
+		delivered := bot.deliverGifts(ctx, giftRequest{
+			dedupKey:  dedupKey,
+			giver:     giver,
+			channel:   in.channel,
+			ts:        ts,
+			synthetic: true,
+			eventTime: now,
+			gifts:     gifts,
+			note:      note,
+		})
+		if len(delivered) > 0 {
+			bot.sendBeerConfirmation(ctx, in.channel, "", giver, ts, delivered, in.reason)
+		}
+	}
+}
+
+// handleGiveForMessage handles the "Give a beer for this message" shortcut: it opens
+// the gift modal with the message author as recipient and a quote of the message as
+// the reason.
+func (bot *MinimalSlackBot) handleGiveForMessage(cb slack.InteractionCallback) {
+	giver, author := cb.User.ID, cb.Message.User
+	switch {
+	case author == "" || cb.Message.BotID != "":
+		bot.postEphemeral(cb.Channel.ID, giver, "🤖 Bots don't drink beer — pick a message written by a person.")
+		return
+	case author == giver:
+		bot.postEphemeral(cb.Channel.ID, giver, "🚫 You can't give beers to yourself.")
+		return
+	}
+	bot.openGiveModal(cb.TriggerID, giver, giveModalInput{
+		recipients: []string{author},
+		quantity:   1,
+		reason:     quoteMessage(cb.Message.Text, maxGiveReason),
+		channel:    cb.Channel.ID,
+		source:     cb.Channel.ID + "/" + cb.Message.Timestamp,
+	})
+}
+
+// quoteMessage renders message text as a single-line quote of at most limit runes.
+func quoteMessage(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) > limit-2 {
+		runes = append(runes[:limit-3], '…')
+	}
+	return "“" + string(runes) + "”"
+}
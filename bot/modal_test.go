@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+func giveCallback(giver string, recipients []string, quantity, reason, channel string) slack.InteractionCallback {
+	var cb slack.InteractionCallback
+	cb.Type = slack.InteractionTypeViewSubmission
+	cb.User.ID = giver
+	cb.View.ID = "V123"
+	cb.View.CallbackID = giveCallbackID
+	cb.View.State = &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+		giveRecipients: {giveRecipients: {SelectedUsers: recipients}},
+		giveQuantity:   {giveQuantity: {SelectedOption: slack.OptionBlockObject{Value: quantity}}},
+		giveReason:     {giveReason: {Value: reason}},
+		giveChannel:    {giveChannel: {SelectedConversation: channel}},
+	}}
+	return cb
+}
+
+func TestGiveModalSubmission(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.maxPerDay = 10 })
+
+	// giving to yourself keeps the modal open with an inline error
+	resp, followUp := bot.handleInteraction(ctx, giveCallback("UG", []string{"UA", "UG"}, "2", "", "C1"))
+	errResp, ok := resp.(*slack.ViewSubmissionResponse)
+	if !ok || errResp.Errors[giveRecipients] == "" || followUp != nil {
+		t.Fatalf("expected recipients error, got %#v", resp)
+	}
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("invalid submission must not store gifts, got %v", beers)
+	}
+
+	// a valid submission is ACKed plainly and delivered afterwards
+	resp, followUp = bot.handleInteraction(ctx, giveCallback("UG", []string{"UA", "UB"}, "2", " for the release ", "C1"))
+	if resp != nil || followUp == nil {
+		t.Fatalf("expected plain ACK with follow-up, got %#v", resp)
+	}
+	followUp()
+	if beers := received(t, ms); beers["UA"] != 2 || beers["UB"] != 2 {
+		t.Fatalf("expected 2 beers each, got %v", beers)
+	}
+	if gift := giftTo(t, ms, "UA"); gift.Reason != "for the release" || gift.ChannelID != "C1" || gift.Permalink != "" {
+		t.Fatalf("unexpected gift %+v", gift)
+	}
+	if got := statuses(ms); len(got) != 2 || got[0] != "success" {
+		t.Fatalf("expected two success outcomes, got %v", got)
+	}
+
+	// exhausted daily budget is reported on the quantity field
+	_ = ms.AddBeer(ctx, "UG", "UD", "1.1", time.Now().In(bot.userLocation("UG")), 6, storage.BeerNote{})
+	resp, _ = bot.handleInteraction(ctx, giveCallback("UG", []string{"UC"}, "1", "", "C1"))
+	if errResp, ok := resp.(*slack.ViewSubmissionResponse); !ok || errResp.Errors[giveQuantity] == "" {
+		t.Fatalf("expected quantity error, got %#v", resp)
+	}
+}
+
+// failingBudget fails every daily budget lookup.
+type failingBudget struct {
+	storage.Store
+}
+
+func (failingBudget) CountGivenOnDate(context.Context, string, string) (int, error) {
+	return 0, errors.New("database is locked")
+}
+
+func TestGiveModalSubmission_BudgetLookupFails(t *testing.T) {
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, failingBudget{ms}, func(b *MinimalSlackBot) { b.maxPerDay = 10 })
+
+	resp, followUp := bot.handleInteraction(t.Context(), giveCallback("UG", []string{"UA"}, "1", "", "C1"))
+	if errResp, ok := resp.(*slack.ViewSubmissionResponse); !ok || errResp.Errors[giveQuantity] == "" || followUp != nil {
+		t.Fatalf("expected quantity error, got %#v", resp)
+	}
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("unchecked submission must not store gifts, got %v", beers)
+	}
+}
+
+func TestGiveModal_Prefill(t *testing.T) {
+	bot := &MinimalSlackBot{maxGift: 5}
+	view := bot.giveModal(giveModalInput{recipients: []string{"UA"}, quantity: 9, reason: "for lunch", channel: "C1"})
+	if view.CallbackID != giveCallbackID || len(view.Blocks.BlockSet) != 4 {
+		t.Fatalf("unexpected view %+v", view)
+	}
+	users := view.Blocks.BlockSet[0].(*slack.InputBlock).Element.(*slack.MultiSelectBlockElement)
+	if len(users.InitialUsers) != 1 || users.InitialUsers[0] != "UA" {
+		t.Fatalf("expected prefilled recipient, got %v", users.InitialUsers)
+	}
+	quantity := view.Blocks.BlockSet[1].(*slack.InputBlock).Element.(*slack.SelectBlockElement)
+	if len(quantity.Options) != 5 || quantity.InitialOption.Value != "5" {
+		t.Fatalf("expected quantity capped at MAX_BEER_GIFT, got %d options, initial %+v", len(quantity.Options), quantity.InitialOption)
+	}
+}
+
+func TestGiveForMessageShortcut(t *testing.T) {
+	ctx := t.Context()
+	var opened slack.ModalViewRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/views.open") {
+			var req struct {
+				View slack.ModalViewRequest `json:"view"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			opened = req.View
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	bot := newTestBot(t, storage.NewMemoryStore(), func(b *MinimalSlackBot) { b.api = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/")) })
+
+	var cb slack.InteractionCallback
+	cb.Type = slack.InteractionTypeMessageAction
+	cb.CallbackID = giveForMessage
+	cb.TriggerID = "trigger"
+	cb.User.ID = "UG"
+	cb.Channel.ID = "C1"
+	cb.Message.User = "UA"
+	cb.Message.Timestamp = "1717691574.000100"
+	cb.Message.Text = "Fixed the   flaky\ndeploy"
+
+	resp, followUp := bot.handleInteraction(ctx, cb)
+	if resp != nil || followUp == nil {
+		t.Fatalf("expected plain ACK with follow-up, got %#v", resp)
+	}
+	followUp()
+	if opened.PrivateMetadata != "C1/1717691574.000100" {
+		t.Fatalf("expected source message in private metadata, got %q", opened.PrivateMetadata)
+	}
+	users := opened.Blocks.BlockSet[0].(*slack.InputBlock).Element.(*slack.MultiSelectBlockElement)
+	if len(users.InitialUsers) != 1 || users.InitialUsers[0] != "UA" {
+		t.Fatalf("expected author as recipient, got %v", users.InitialUsers)
+	}
+	reason := opened.Blocks.BlockSet[2].(*slack.InputBlock).Element.(*slack.PlainTextInputBlockElement)
+	if reason.InitialValue != "“Fixed the flaky deploy”" {
+		t.Fatalf("unexpected reason %q", reason.InitialValue)
+	}
+
+	if q := quoteMessage(strings.Repeat("a", 20), 10); q != "“aaaaaaa…”" || len([]rune(q)) != 10 {
+		t.Fatalf("unexpected truncated quote %q", q)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+// Notification modes of /beer-settings notify=…
+const (
+	notifyOff     = "off"
+	notifyInstant = "instant"
+	notifyDaily   = "daily"
+)
+
+// digestCheckInterval is how often the daily digest loop looks for due digests.
+const digestCheckInterval = 10 * time.Minute
+
+func validNotify(mode string) bool {
+	return mode == notifyOff || mode == notifyInstant || mode == notifyDaily
+}
+
+// notifyMode returns the user's notification mode, falling back to NOTIFY_DEFAULT.
+func (bot *MinimalSlackBot) notifyMode(ctx context.Context, user string) string {
+	prefs, err := bot.store.GetPreferences(ctx, user)
+	if err != nil {
+		bot.logger.Debug().Err(err).Str("user", user).Msg("Failed to load preferences")
+	}
+	if validNotify(prefs.Notify) {
+		return prefs.Notify
+	}
+	if validNotify(bot.notifyDefault) {
+		return bot.notifyDefault
+	}
+	return notifyOff
+}
+
+// handleBeerSettings implements /beer-settings [notify=off|instant|daily].
+func (bot *MinimalSlackBot) handleBeerSettings(ctx context.Context, cmd slack.SlashCommand) {
+	bot.postEphemeral(cmd.ChannelID, cmd.UserID, bot.beerSettings(ctx, cmd.UserID, strings.Fields(cmd.Text)))
+}
+
+// beerSettings applies /beer-settings arguments and returns the reply text.
+func (bot *MinimalSlackBot) beerSettings(ctx context.Context, user string, args []string) string {
+	for _, a := range args {
+		k, v, ok := strings.Cut(strings.ToLower(a), "=")
+		if !ok || k != "notify" || !validNotify(v) {
+			return "Usage: `/beer-settings notify=off|instant|daily`"
+		}
+		if err := bot.store.SetNotify(ctx, user, v); err != nil {
+			bot.logger.Error().Err(err).Str("user", user).Msg("Failed to store preferences")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			return "⚠️ Could not save your settings, please try again."
+		}
+		// A new daily subscriber starts with gifts from now on
+		if v == notifyDaily {
+			_ = bot.store.SetDigestSent(ctx, user, time.Now())
+		}
+	}
+	desc := map[string]string{
+		notifyOff:     "off — no direct messages",
+		notifyInstant: "instant — a direct message for every gift you receive",
+		notifyDaily:   fmt.Sprintf("daily — one direct message around %02d:00 with the day's gifts", bot.digestHour),
+	}
+	prefix := "Your settings"
+	if len(args) > 0 {
+		prefix = "✅ Saved"
+	}
+	return fmt.Sprintf("%s:\n• Notifications: %s", prefix, desc[bot.notifyMode(ctx, user)])
+}
+
+// notifyRecipients sends an instant DM to every recipient who asked for one.
+func (bot *MinimalSlackBot) notifyRecipients(ctx context.Context, req giftRequest, delivered []recipientGift) {
+	for _, g := range delivered {
+		if bot.notifyMode(ctx, g.recipient) != notifyInstant {
+			continue
+		}
+		text := fmt.Sprintf("🍺 <@%s> gave you %s", req.giver, beerCount(g.quantity))
+		if req.note.ChannelID != "" {
+			text += fmt.Sprintf(" in <#%s>", req.note.ChannelID)
+		}
+		if req.note.Reason != "" {
+			text += " — " + req.note.Reason
+		}
+		if req.note.Permalink != "" {
+			text += fmt.Sprintf("\n<%s|View message>", req.note.Permalink)
+		}
+		bot.sendDM(g.recipient, text)
+	}
+}
+
+// sendDM posts a message to the user's conversation with the app.
+func (bot *MinimalSlackBot) sendDM(user, text string) bool {
+	if _, _, err := bot.api.PostMessage(user, slack.MsgOptionText(text, false)); err != nil {
+		bot.logger.Warn().Err(err).Str("user", user).Msg("Failed to send direct message")
+		bot.errorCounter.WithLabelValues("message_error").Inc()
+		return false
+	}
+	bot.eventCounter.WithLabelValues("notification", "sent").Inc()
+	return true
+}
+
+// runDigests sends the daily notification digests until the bot stops.
+func (bot *MinimalSlackBot) runDigests(ctx context.Context) {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bot.stop:
+			return
+		case now := <-ticker.C:
+			bot.sendDueDigests(ctx, now)
+		}
+	}
+}
+
+// sendDueDigests sends a digest to every daily subscriber whose local digest hour
+// has passed today and who has not had today's digest yet.
+func (bot *MinimalSlackBot) sendDueDigests(ctx context.Context, now time.Time) {
+	prefs, err := bot.store.ListPreferences(ctx)
+	if err != nil {
+		bot.logger.Error().Err(err).Msg("Failed to load preferences for digests")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	subscribers := map[string]storage.Preferences{}
+	explicit := map[string]bool{}
+	for _, p := range prefs {
+		if validNotify(p.Notify) {
+			explicit[p.User] = true
+		}
+		if p.Notify == notifyDaily || (!validNotify(p.Notify) && bot.notifyDefault == notifyDaily) {
+			subscribers[p.User] = p
+		}
+	}
+	// With NOTIFY_DEFAULT=daily every recipient without an explicit choice gets one
+	if bot.notifyDefault == notifyDaily {
+		recipients, err := bot.store.GetAllRecipients(ctx)
+		if err != nil {
+			bot.logger.Error().Err(err).Msg("Failed to load recipients for digests")
+			return
+		}
+		for _, r := range recipients {
+			if _, ok := subscribers[r]; !ok && !explicit[r] {
+				subscribers[r] = storage.Preferences{User: r}
+			}
+		}
+	}
+
+	for user, p := range subscribers {
+		local := now.In(bot.userLocation(user))
+		today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+		slot := today.Add(time.Duration(bot.digestHour) * time.Hour)
+		if local.Hour() < bot.digestHour || !p.DigestSent.Before(slot) {
+			continue
+		}
+		// Claim the user's digest for today so that only one replica sends it
+		name := "dm:" + user
+		claimed, err := bot.store.ClaimDigestRun(ctx, name, slot)
+		if err != nil {
+			bot.logger.Error().Err(err).Str("user", user).Msg("Failed to claim digest")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		status := bot.sendDigest(ctx, user, p, today, now)
+		if err := bot.store.FinishDigestRun(ctx, name, slot, status, "", ""); err != nil {
+			bot.logger.Error().Err(err).Str("user", user).Msg("Failed to record digest run")
+		}
+	}
+}
+
+// sendDigest DMs a user the gifts received since their last digest and returns the
+// status of the run: posted, empty or failed. A failed digest leaves the last digest
+// time alone, so the next day's digest includes its gifts.
+func (bot *MinimalSlackBot) sendDigest(ctx context.Context, user string, p storage.Preferences, today, now time.Time) string {
+	since := p.DigestSent
+	if since.IsZero() {
+		since = today
+	}
+	gifts, err := bot.store.ReceivedSince(ctx, user, since)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("user", user).Msg("Failed to load gifts for digest")
+		return "failed"
+	}
+	status := "empty"
+	if len(gifts) > 0 {
+		if !bot.sendDM(user, formatDigest(gifts)) {
+			return "failed"
+		}
+		status = "posted"
+	}
+	if err := bot.store.SetDigestSent(ctx, user, now); err != nil {
+		bot.logger.Error().Err(err).Str("user", user).Msg("Failed to record digest")
+	}
+	return status
+}
+
+// formatDigest renders a daily digest of received gifts.
+func formatDigest(gifts []storage.BeerRecord) string {
+	total := 0
+	for _, g := range gifts {
+		total += g.Count
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🍻 You received %s since your last digest:", beerCount(total))
+	for _, g := range gifts {
+		line := fmt.Sprintf("%s %s", giverMention(g.Giver), beerCount(g.Count))
+		if g.ChannelID != "" {
+			line += fmt.Sprintf(" in <#%s>", g.ChannelID)
+		}
+		if g.Reason != "" {
+			line += " — " + g.Reason
+		}
+		if g.Permalink != "" {
+			line += fmt.Sprintf(" (<%s|view>)", g.Permalink)
+		}
+		b.WriteString("\n• " + line)
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+	_ "modernc.org/sqlite"
+)
+
+// dmRecorder is a Slack API stub that records chat.postMessage calls as "channel: text".
+func dmRecorder(t *testing.T, sent *[]string) *slack.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/chat.postMessage") {
+			_ = r.ParseForm()
+			*sent = append(*sent, r.FormValue("channel")+": "+r.FormValue("text"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"D1","ts":"1717691574.000100"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+}
+
+func TestNotifyRecipients_Instant(t *testing.T) {
+	ctx := t.Context()
+	var sent []string
+	ms := storage.NewMemoryStore()
+	_ = ms.SetNotify(ctx, "UA", notifyInstant)
+	_ = ms.SetNotify(ctx, "UB", notifyDaily)
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) {
+		b.api = dmRecorder(t, &sent)
+		b.maxPerDay = 10
+	})
+
+	req := giftRequest{
+		dedupKey:  "E1",
+		giver:     "UG",
+		channel:   "C1",
+		ts:        "1.1",
+		synthetic: true,
+		eventTime: time.Now(),
+		gifts:     []recipientGift{{recipient: "UA", quantity: 2}, {recipient: "UB", quantity: 1}, {recipient: "UC", quantity: 1}},
+		note:      storage.BeerNote{Reason: "for the demo", ChannelID: "C1", Permalink: "https://example.slack.com/archives/C1/p1"},
+	}
+	bot.deliverGifts(ctx, req)
+	want := "UA: 🍺 <@UG> gave you 2 beers in <#C1> — for the demo\n<https://example.slack.com/archives/C1/p1|View message>"
+	if len(sent) != 1 || sent[0] != want {
+		t.Fatalf("expected one instant DM to UA, got %q", sent)
+	}
+
+	// amended gifts do not notify again
+	sent = nil
+	req.dedupKey, req.status = "E2", "amended"
+	bot.deliverGifts(ctx, req)
+	if len(sent) != 0 {
+		t.Fatalf("expected no DM for an edit, got %q", sent)
+	}
+}
+
+func TestSendDueDigests(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "digest.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := storage.NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	var sent []string
+	bot := newTestBot(t, s, func(b *MinimalSlackBot) {
+		b.api = dmRecorder(t, &sent)
+		b.digestHour = 17
+	})
+
+	if reply := bot.beerSettings(ctx, "UA", []string{"notify=daily"}); !strings.Contains(reply, "daily") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	if reply := bot.beerSettings(ctx, "UA", []string{"notify=loud"}); !strings.HasPrefix(reply, "Usage") {
+		t.Fatalf("unexpected reply: %s", reply)
+	}
+	yesterday := time.Date(2024, 5, 14, 18, 0, 0, 0, time.UTC)
+	_ = s.SetDigestSent(ctx, "UA", yesterday)
+	_ = s.AddBeer(ctx, "UG", "UA", "1.1", yesterday.Add(16*time.Hour), 2, storage.BeerNote{Reason: "for the fix"})
+	_ = s.AddBeer(ctx, "UG", "UB", "1.2", yesterday.Add(16*time.Hour), 1, storage.BeerNote{}) // UB has no subscription
+
+	bot.sendDueDigests(ctx, time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC))
+	if len(sent) != 0 {
+		t.Fatalf("digest sent before the digest hour: %q", sent)
+	}
+	bot.sendDueDigests(ctx, time.Date(2024, 5, 15, 17, 5, 0, 0, time.UTC))
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "UA: 🍻 You received 2 beers") || !strings.Contains(sent[0], "for the fix") {
+		t.Fatalf("unexpected digests %q", sent)
+	}
+	bot.sendDueDigests(ctx, time.Date(2024, 5, 15, 17, 15, 0, 0, time.UTC))
+	if len(sent) != 1 {
+		t.Fatalf("digest must be sent once a day, got %q", sent)
+	}
+
+	// a replica that read the preferences before the digest went out does not send it again
+	stale, err := s.ListPreferences(ctx)
+	if err != nil {
+		t.Fatalf("list preferences: %v", err)
+	}
+	for i := range stale {
+		stale[i].DigestSent = yesterday
+	}
+	replica := newTestBot(t, &stalePreferences{Store: s, prefs: stale}, func(b *MinimalSlackBot) {
+		b.api = dmRecorder(t, &sent)
+		b.digestHour = 17
+	})
+	replica.sendDueDigests(ctx, time.Date(2024, 5, 15, 17, 10, 0, 0, time.UTC))
+	if len(sent) != 1 {
+		t.Fatalf("digest must be sent by one replica only, got %q", sent)
+	}
+}
+
+// stalePreferences returns a fixed snapshot of the preferences, as read by a replica
+// just before another one sent the digests.
+type stalePreferences struct {
+	storage.Store
+	prefs []storage.Preferences
+}
+
+func (s *stalePreferences) ListPreferences(ctx context.Context) ([]storage.Preferences, error) {
+	return s.prefs, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// defaultBeerReactions are the reactions that count as a beer when BEER_REACTIONS is unset.
+var defaultBeerReactions = []string{"beer", "beers"}
+
+// parseReactionList parses a comma separated list of reaction names, tolerating
+// surrounding colons (":beer:" and "beer" are equivalent). An empty list yields the defaults.
+func parseReactionList(v string) map[string]bool {
+	out := map[string]bool{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.Trim(strings.TrimSpace(name), ":")
+		if name != "" {
+			out[name] = true
+		}
+	}
+	if len(out) == 0 {
+		for _, name := range defaultBeerReactions {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+// isBeerReaction reports whether a reaction name counts as a beer.
+// Skin-tone variants ("beer::skin-tone-2") count like their base reaction.
+func (bot *MinimalSlackBot) isBeerReaction(name string) bool {
+	if i := strings.Index(name, "::"); i >= 0 {
+		name = name[:i]
+	}
+	if bot.reactions == nil {
+		for _, r := range defaultBeerReactions {
+			if r == name {
+				return true
+			}
+		}
+		return false
+	}
+	return bot.reactions[name]
+}
+
+// handleReactionAdded gives one beer from the reacting user to the author of the
+// message they reacted to. Reaction gifts are keyed by the reacted message's ts, so
+// several beer reactions by the same user on one message still count as one beer.
+// Reacting to your own message is ignored silently.
+func (bot *MinimalSlackBot) handleReactionAdded(ctx context.Context, event *slackevents.ReactionAddedEvent, envelopeID string) {
+	if event.Item.Type != "message" || event.ItemUser == "" || event.ItemUser == event.User || !bot.isBeerReaction(event.Reaction) {
+		return
+	}
+	if !bot.channelAllowed(event.Item.Channel, "") {
+		return
+	}
+	bot.eventCounter.WithLabelValues("reaction", "received").Inc()
+
+	dedupKey := envelopeID
+	if dedupKey == "" {
+		dedupKey = "reaction_added:" + event.User + ":" + event.Item.Timestamp + ":" + event.Reaction
+	}
+	eventTime := parseSlackTS(event.EventTimestamp).In(bot.userLocation(event.User))
+	if !bot.markEventProcessed(ctx, dedupKey, event.User, eventTime) {
+		return
+	}
+
+	delivered := bot.deliverGifts(ctx, giftRequest{
+		dedupKey:  dedupKey,
+		giver:     event.User,
+		channel:   event.Item.Channel,
+		ts:        event.Item.Timestamp,
+		eventTime: eventTime,
+		gifts:     []recipientGift{{recipient: event.ItemUser, quantity: 1}},
+		note:      storage.BeerNote{ChannelID: event.Item.Channel},
+	})
+	if len(delivered) == 0 && ctx.Err() != nil {
+		bot.unmarkEventProcessed(ctx, dedupKey)
+	}
+}
+
+// handleReactionRemoved revokes a reaction gift once the user no longer has any
+// beer reaction on the message. The gift stays on record with revoked_at set, and
+// reacting again gives it back.
+func (bot *MinimalSlackBot) handleReactionRemoved(ctx context.Context, event *slackevents.ReactionRemovedEvent, envelopeID string) {
+	if event.Item.Type != "message" || event.ItemUser == "" || event.ItemUser == event.User || !bot.isBeerReaction(event.Reaction) {
+		return
+	}
+	if !bot.channelAllowed(event.Item.Channel, "") {
+		return
+	}
+	bot.eventCounter.WithLabelValues("reaction", "received").Inc()
+
+	dedupKey := envelopeID
+	if dedupKey == "" {
+		dedupKey = "reaction_removed:" + event.User + ":" + event.Item.Timestamp + ":" + event.Reaction
+	}
+	eventTime := parseSlackTS(event.EventTimestamp).In(bot.userLocation(event.User))
+	if !bot.markEventProcessed(ctx, dedupKey, event.User, eventTime) {
+		return
+	}
+
+	if bot.hasOtherBeerReaction(event.Item.Channel, event.Item.Timestamp, event.User) {
+		bot.logger.Debug().
+			Str("user", event.User).
+			Str("item_ts", event.Item.Timestamp).
+			Msg("User still has a beer reaction on the message, keeping gift")
+		return
+	}
+
+	// The original reaction may never have counted (over budget, disallowed
+	// channel) or may be revoked already; then there is nothing to revoke.
+	counts, err := bot.store.GetBeersByTS(ctx, event.User, event.Item.Timestamp)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", event.User).Str("ts", event.Item.Timestamp).Msg("Failed to load reaction gift")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return
+	}
+	if counts[event.ItemUser] == 0 {
+		bot.logger.Debug().
+			Str("user", event.User).
+			Str("item_ts", event.Item.Timestamp).
+			Msg("No active reaction gift to revoke")
+		return
+	}
+	bot.revokeGifts(ctx, dedupKey, event.User, event.Item.Timestamp, []string{event.ItemUser}, counts, eventTime)
+}
+
+// hasOtherBeerReaction reports whether user still has a counting reaction on the message.
+// Lookup failures are treated as "no" so a removal is never silently ignored.
+func (bot *MinimalSlackBot) hasOtherBeerReaction(channel, ts, user string) bool {
+	if bot.api == nil {
+		return false
+	}
+	reactions, err := bot.api.GetReactions(slack.NewRefToMessage(channel, ts), slack.NewGetReactionsParameters())
+	if err != nil {
+		bot.logger.Debug().Err(err).Msg("Failed to fetch message reactions")
+		return false
+	}
+	for _, r := range reactions {
+		if !bot.isBeerReaction(r.Name) {
+			continue
+		}
+		for _, u := range r.Users {
+			if u == user {
+				return true
+			}
+		}
+	}
+	return false
+}
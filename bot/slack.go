@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/slack-go/slack"
@@ -22,16 +24,73 @@ type MinimalSlackBot struct {
 	api          *slack.Client
 	client       *socketmode.Client
 	logger       zerolog.Logger
-	store        Store
+	store        storage.Store
 	eventCounter *prometheus.CounterVec
 	errorCounter *prometheus.CounterVec
 	maxGift      int
+	maxPerDay    int
 	readOnly     bool
 	traceEvents  bool
+	reactions    map[string]bool // reaction names (without colons) that count as a beer
+	vocab        *giftVocabulary // emoji, keywords and verbs that express a gift
+
+	// Thread replies only count when enabled globally or for their channel
+	threadGifts     bool
+	threadChannels  map[string]bool
+	threadBroadcast bool
+
+	// How long a gift can be undone via its Undo button or /beer-undo; 0 disables undo
+	undoWindow time.Duration
+
+	// Gift DMs to recipients: default mode for users without a /beer-settings choice
+	// and the local hour of the daily digest
+	notifyDefault string
+	digestHour    int
+
+	// Scheduled channel digest; nil when DIGEST_SCHEDULE is not set
+	digest *digestConfig
+
+	// Users who opened the Home tab; their view is republished when their beers change
+	homeMu    sync.Mutex
+	homeUsers map[string]bool
+
+	// Where gifting counts, and who may run /beer-admin (ADMIN_USERS or members of ADMIN_USERGROUP)
+	channels     *channelPolicy
+	admins       map[string]bool
+	adminGroup   string
+	adminMu      sync.Mutex
+	groupMembers map[string]bool
+	groupFetched time.Time
+
+	// Day boundaries: workspace time zone, optionally overridden by the Slack profile tz of each user
+	location  *time.Location
+	userTZ    bool
+	tzMu      sync.Mutex
+	userZones map[string]cachedZone
+
+	// Deadline for the storage work of one Slack event, command or interaction (REQUEST_TIMEOUT)
+	requestTimeout time.Duration
+
+	// When the /beer-admin settings and channel rules were last read from the store
+	settingsLoaded time.Time
+
+	// Closed by Stop; the event loop and digest schedulers in running exit when it is
+	stop    chan struct{}
+	running sync.WaitGroup
 }
 
-// NewMinimalSlackBot creates a new minimal Slack bot instance
-func NewMinimalSlackBot(botToken, appToken string, store Store, logger zerolog.Logger) (*MinimalSlackBot, error) {
+// cachedZone is a user's Slack profile time zone together with its lookup time.
+type cachedZone struct {
+	loc     *time.Location
+	fetched time.Time
+}
+
+// userZoneTTL bounds how long a looked-up user time zone is reused.
+const userZoneTTL = 12 * time.Hour
+
+// NewMinimalSlackBot creates a new minimal Slack bot instance. ctx bounds the
+// storage reads done while loading runtime settings.
+func NewMinimalSlackBot(ctx context.Context, botToken, appToken string, store storage.Store, logger zerolog.Logger) (*MinimalSlackBot, error) {
 	if botToken == "" {
 		return nil, errors.New("bot token is required")
 	}
@@ -75,10 +134,97 @@ func NewMinimalSlackBot(botToken, appToken string, store Store, logger zerolog.L
 			maxGift = n
 		}
 	}
+	// Daily budget per giver; 0 disables the limit
+	maxPerDay := 10
+	if v := strings.TrimSpace(os.Getenv("MAX_PER_DAY")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxPerDay = n
+		}
+	}
 	readOnly := strings.EqualFold(os.Getenv("READ_ONLY"), "true") || os.Getenv("READ_ONLY") == "1"
 	traceEvents := strings.EqualFold(os.Getenv("TRACE_EVENTS"), "true") || os.Getenv("TRACE_EVENTS") == "1"
 
-	return &MinimalSlackBot{
+	// Time zones: WORKSPACE_TZ is an IANA name (e.g. Europe/Berlin); USER_TZ=true prefers each user's Slack tz
+	location := time.UTC
+	if v := strings.TrimSpace(os.Getenv("WORKSPACE_TZ")); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WORKSPACE_TZ %q: %w", v, err)
+		}
+		location = loc
+	}
+	userTZ := strings.EqualFold(os.Getenv("USER_TZ"), "true") || os.Getenv("USER_TZ") == "1"
+
+	// Gift vocabulary: GIFT_EMOJI (with optional weights, e.g. ":prost:=2"), GIFT_KEYWORDS and GIFT_VERBS
+	// replace the built-in lists; the legacy EMOJI variable adds one more emoji
+	giftEmoji := envOr("GIFT_EMOJI", defaultGiftEmoji)
+	if v := strings.TrimSpace(os.Getenv("EMOJI")); v != "" {
+		giftEmoji += "," + v
+	}
+	vocab, err := newGiftVocabulary(giftEmoji, envOr("GIFT_KEYWORDS", defaultGiftKeywords), envOr("GIFT_VERBS", defaultGiftVerbs))
+	if err != nil {
+		return nil, err
+	}
+
+	// Reactions that give a beer to the message author, e.g. BEER_REACTIONS=beer,beers,beer-mug
+	reactions := parseReactionList(os.Getenv("BEER_REACTIONS"))
+
+	// Thread gifting: THREAD_GIFTS=true for all channels or THREAD_GIFT_CHANNELS=C1,C2 for some;
+	// THREAD_BROADCAST=true also shows the in-thread confirmation in the channel
+	threadGifts := strings.EqualFold(os.Getenv("THREAD_GIFTS"), "true") || os.Getenv("THREAD_GIFTS") == "1"
+	threadChannels := parseIDList(os.Getenv("THREAD_GIFT_CHANNELS"))
+	threadBroadcast := strings.EqualFold(os.Getenv("THREAD_BROADCAST"), "true") || os.Getenv("THREAD_BROADCAST") == "1"
+
+	// Channel lists: IDs or types (public, private, im, mpim); the legacy CHANNEL variable joins the allow list
+	allow := os.Getenv("CHANNEL_ALLOW")
+	if v := strings.TrimSpace(os.Getenv("CHANNEL")); v != "" {
+		allow = v + "," + allow
+	}
+	channels, err := newChannelPolicy(allow, os.Getenv("CHANNEL_DENY"))
+	if err != nil {
+		return nil, err
+	}
+	if err := channels.load(ctx, store); err != nil {
+		return nil, fmt.Errorf("load channel rules: %w", err)
+	}
+	admins := parseIDList(os.Getenv("ADMIN_USERS"))
+	adminGroup := strings.TrimSpace(os.Getenv("ADMIN_USERGROUP"))
+
+	// Undo window, e.g. UNDO_WINDOW=10m; 0 disables undo
+	undoWindow := defaultUndoWindow
+	if v := strings.TrimSpace(os.Getenv("UNDO_WINDOW")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid UNDO_WINDOW %q", v)
+		}
+		undoWindow = d
+	}
+
+	// Recipient notifications: NOTIFY_DEFAULT=off|instant|daily, NOTIFY_DIGEST_HOUR=0..23
+	notifyDefault := strings.ToLower(envOr("NOTIFY_DEFAULT", notifyOff))
+	if !validNotify(notifyDefault) {
+		return nil, fmt.Errorf("invalid NOTIFY_DEFAULT %q: use off, instant or daily", notifyDefault)
+	}
+	digestHour := 17
+	if v := strings.TrimSpace(os.Getenv("NOTIFY_DIGEST_HOUR")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 23 {
+			return nil, fmt.Errorf("invalid NOTIFY_DIGEST_HOUR %q", v)
+		}
+		digestHour = n
+	}
+
+	digest, err := newDigestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	requestTimeout, err := requestTimeoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	bot := &MinimalSlackBot{
 		api:          api,
 		client:       client,
 		logger:       logger,
@@ -86,43 +232,134 @@ func NewMinimalSlackBot(botToken, appToken string, store Store, logger zerolog.L
 		eventCounter: eventCounter,
 		errorCounter: errorCounter,
 		maxGift:      maxGift,
+		maxPerDay:    maxPerDay,
 		readOnly:     readOnly,
 		traceEvents:  traceEvents,
-	}, nil
+		reactions:    reactions,
+		vocab:        vocab,
+
+		threadGifts:     threadGifts,
+		threadChannels:  threadChannels,
+		threadBroadcast: threadBroadcast,
+		channels:        channels,
+		admins:          admins,
+		adminGroup:      adminGroup,
+		undoWindow:      undoWindow,
+		notifyDefault:   notifyDefault,
+		digestHour:      digestHour,
+		digest:          digest,
+		location:        location,
+		userTZ:          userTZ,
+		userZones:       map[string]cachedZone{},
+		requestTimeout:  requestTimeout,
+		stop:            make(chan struct{}),
+	}
+	// Settings changed with /beer-admin override the environment
+	if err := bot.loadSettings(ctx); err != nil {
+		return nil, fmt.Errorf("load settings: %w", err)
+	}
+	bot.settingsLoaded = time.Now()
+	return bot, nil
 }
 
-// Start runs the Slack bot with minimal Socket Mode setup
-func (bot *MinimalSlackBot) Start() error {
+// Start runs the Slack bot with minimal Socket Mode setup. ctx is the parent of
+// all storage work; canceling it aborts whatever is still running.
+func (bot *MinimalSlackBot) Start(ctx context.Context) error {
 	bot.logger.Info().Msg("Starting minimal Slack bot...")
 
 	// Set up event handlers
-	go bot.handleEvents()
+	bot.running.Go(func() { bot.handleEvents(ctx) })
+	bot.running.Go(func() { bot.runDigests(ctx) })
+	if bot.digest != nil {
+		bot.running.Go(func() { bot.runScheduledDigest(ctx) })
+	}
 
 	// Run the Socket Mode client - this is the key simplification
 	// No custom reconnection logic, just use the library's built-in handling
-	return bot.client.Run()
+	return bot.client.RunContext(ctx)
 }
 
-// Stop attempts to close the socketmode client connection.
-func (bot *MinimalSlackBot) Stop() {
-	if bot.client != nil {
-		// Best-effort: socketmode.Client offers a Debugf and internal connection handling; no public close.
-		bot.logger.Info().Msg("Stopping Slack bot (process will exit)")
+// Stop stops taking new events and waits until the events already taken and any
+// running digest are handled, or ctx is done. Events that were not taken yet stay
+// unacknowledged, so Slack redelivers them.
+func (bot *MinimalSlackBot) Stop(ctx context.Context) error {
+	bot.logger.Info().Msg("Stopping Slack bot")
+	close(bot.stop)
+	done := make(chan struct{})
+	go func() {
+		bot.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-// handleEvents processes incoming Slack events
-func (bot *MinimalSlackBot) handleEvents() {
+// eventQueueSize bounds the events taken from Socket Mode but not yet handled.
+const eventQueueSize = 64
+
+// handleEvents takes incoming Slack events until Stop is called. Events are queued
+// for a single worker and Events API and slash command envelopes are ACKed as soon
+// as they are queued, well within Slack's 3 second deadline however long handling
+// takes. The worker finishes the queue before handleEvents returns.
+func (bot *MinimalSlackBot) handleEvents(ctx context.Context) {
 	bot.logger.Info().Msg("Event handler loop started - waiting for Socket Mode events...")
-	for event := range bot.client.Events {
-		bot.logger.Debug().Msg(">>> NEW EVENT FROM SOCKET MODE CHANNEL <<<")
-		bot.processEvent(event)
+	queue := make(chan socketmode.Event, eventQueueSize)
+	var worker sync.WaitGroup
+	worker.Go(func() {
+		for event := range queue {
+			bot.processEvent(ctx, event)
+		}
+	})
+	defer func() {
+		close(queue)
+		worker.Wait()
+	}()
+
+	for {
+		select {
+		case <-bot.stop:
+			bot.logger.Info().Msg("Event handler loop stopped")
+			return
+		case event, ok := <-bot.client.Events:
+			if !ok {
+				bot.logger.Warn().Msg("Event handler loop ended - Socket Mode channel closed")
+				return
+			}
+			bot.logger.Debug().Msg(">>> NEW EVENT FROM SOCKET MODE CHANNEL <<<")
+			select {
+			case queue <- event:
+			case <-bot.stop:
+				bot.logger.Info().Msg("Event handler loop stopped")
+				return // not ACKed, so Slack redelivers it
+			}
+			// Interactive envelopes are ACKed by their handler since the ACK may carry
+			// a response (e.g. modal errors)
+			if (event.Type == socketmode.EventTypeEventsAPI || event.Type == socketmode.EventTypeSlashCommand) && event.Request != nil {
+				bot.client.Ack(*event.Request)
+			}
+		}
+	}
+}
+
+// requestContext derives the context for one event, command or interaction,
+// bounded by REQUEST_TIMEOUT when one is configured.
+func (bot *MinimalSlackBot) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if bot.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	bot.logger.Warn().Msg("Event handler loop ended - Socket Mode channel closed")
+	return context.WithTimeout(ctx, bot.requestTimeout)
 }
 
 // processEvent handles individual Slack events
-func (bot *MinimalSlackBot) processEvent(evt socketmode.Event) {
+func (bot *MinimalSlackBot) processEvent(ctx context.Context, evt socketmode.Event) {
+	ctx, cancel := bot.requestContext(ctx)
+	defer cancel()
+	bot.refreshSettings(ctx)
+
 	// RAW EVENT LOG: Log every incoming socket event before any processing
 	envelopeID := ""
 	if evt.Request != nil {
@@ -135,11 +372,6 @@ func (bot *MinimalSlackBot) processEvent(evt socketmode.Event) {
 		Str("envelope_id", envelopeID).
 		Msg("RAW SOCKET EVENT RECEIVED")
 
-	// ACK only EventsAPI & Interaction events that carry a request envelope
-	if (evt.Type == socketmode.EventTypeEventsAPI || evt.Type == socketmode.EventTypeSlashCommand) && evt.Request != nil {
-		bot.client.Ack(*evt.Request)
-	}
-
 	if bot.traceEvents {
 		bot.logger.Debug().Str("socket_event_type", string(evt.Type)).Msg("Received socket event")
 	}
@@ -152,7 +384,7 @@ func (bot *MinimalSlackBot) processEvent(evt socketmode.Event) {
 			bot.errorCounter.WithLabelValues("cast_error").Inc()
 			return
 		}
-		bot.handleEventsAPIEvent(eventsAPIEvent, envelopeID)
+		bot.handleEventsAPIEvent(ctx, eventsAPIEvent, envelopeID)
 	case socketmode.EventTypeSlashCommand:
 		cmd, ok := evt.Data.(slack.SlashCommand)
 		if !ok {
@@ -160,14 +392,35 @@ func (bot *MinimalSlackBot) processEvent(evt socketmode.Event) {
 			bot.errorCounter.WithLabelValues("cast_error").Inc()
 			return
 		}
-		bot.handleSlashCommand(cmd)
+		bot.handleSlashCommand(ctx, cmd)
+	case socketmode.EventTypeInteractive:
+		cb, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			bot.logger.Error().Msg("Failed to cast event to InteractionCallback")
+			bot.errorCounter.WithLabelValues("cast_error").Inc()
+			if evt.Request != nil {
+				bot.client.Ack(*evt.Request)
+			}
+			return
+		}
+		resp, followUp := bot.handleInteraction(ctx, cb)
+		if evt.Request != nil {
+			if resp != nil {
+				bot.client.Ack(*evt.Request, resp)
+			} else {
+				bot.client.Ack(*evt.Request)
+			}
+		}
+		if followUp != nil {
+			followUp()
+		}
 	default:
 		bot.logger.Trace().Str("event_type", string(evt.Type)).Msg("Ignoring non-EventsAPI event")
 	}
 }
 
 // handleEventsAPIEvent processes Events API events
-func (bot *MinimalSlackBot) handleEventsAPIEvent(event slackevents.EventsAPIEvent, envelopeID string) {
+func (bot *MinimalSlackBot) handleEventsAPIEvent(ctx context.Context, event slackevents.EventsAPIEvent, envelopeID string) {
 	// RAW EVENTSAPI LOG: Log the full event structure before any parsing
 	bot.logger.Debug().
 		Str("api_event_type", string(event.Type)).
@@ -191,7 +444,15 @@ func (bot *MinimalSlackBot) handleEventsAPIEvent(event slackevents.EventsAPIEven
 		switch ev := innerEvent.Data.(type) {
 		case *slackevents.MessageEvent:
 			// Pass the envelope_id for deduplication
-			bot.handleMessage(ev, envelopeID)
+			bot.handleMessage(ctx, ev, envelopeID)
+		case *slackevents.ReactionAddedEvent:
+			bot.handleReactionAdded(ctx, ev, envelopeID)
+		case *slackevents.ReactionRemovedEvent:
+			bot.handleReactionRemoved(ctx, ev, envelopeID)
+		case *slackevents.AppMentionEvent:
+			bot.handleAppMention(ev)
+		case *slackevents.AppHomeOpenedEvent:
+			bot.handleAppHomeOpened(ctx, ev.User, ev.Tab)
 		default:
 			bot.logger.Debug().
 				Str("inner_event_type", innerEvent.Type).
@@ -205,14 +466,31 @@ func (bot *MinimalSlackBot) handleEventsAPIEvent(event slackevents.EventsAPIEven
 }
 
 // handleMessage processes message events for beer giving
-func (bot *MinimalSlackBot) handleMessage(event *slackevents.MessageEvent, envelopeID string) {
-	// Skip bot messages, empty text, edits (subtypes), and thread replies (only handle top-level)
-	if event.BotID != "" || event.Text == "" || event.SubType != "" {
+func (bot *MinimalSlackBot) handleMessage(ctx context.Context, event *slackevents.MessageEvent, envelopeID string) {
+	// Edits and deletions reconcile gifts that were already recorded for the message
+	switch event.SubType {
+	case "message_changed":
+		bot.handleMessageChanged(ctx, event, envelopeID)
+		return
+	case "message_deleted":
+		bot.handleMessageDeleted(ctx, event, envelopeID)
 		return
 	}
-	if event.ThreadTimeStamp != "" && event.ThreadTimeStamp != event.EventTimeStamp {
+
+	// Skip bot messages, empty text and other subtypes; thread replies (including ones
+	// also sent to the channel) only count where thread gifting is enabled
+	if event.BotID != "" || event.Text == "" {
+		return
+	}
+	if event.SubType != "" && !(event.SubType == "thread_broadcast" && bot.allowsThreadGifts(event.Channel)) {
+		return
+	}
+	if isThreadReply(event) && !bot.allowsThreadGifts(event.Channel) {
 		return // ignore replies in threads
 	}
+	if !bot.channelAllowed(event.Channel, event.ChannelType) {
+		return
+	}
 
 	if bot.traceEvents {
 		bot.logger.Debug().Str("channel", event.Channel).Str("user", event.User).Str("text", event.Text).Str("envelope_id", envelopeID).Msg("MessageEvent candidate")
@@ -220,58 +498,18 @@ func (bot *MinimalSlackBot) handleMessage(event *slackevents.MessageEvent, envel
 
 	bot.eventCounter.WithLabelValues("message", "received").Inc()
 
-	if bot.isBeerGiving(event.Text) {
-		bot.processBeerGiving(event, envelopeID)
-	}
-}
-
-// isBeerGiving checks if the message is giving beer to someone
-// compiledGiftPatterns contains a broadened set of regexes that indicate a beer gift intent.
-// We support both emoji-first and mention-first ordering, optional "give" verbs, quantity numbers,
-// and textual/emoji beer variants. Matching only signals intent; quantity extraction is handled separately.
-// NOTE: Keep patterns simple to avoid catastrophic backtracking; prefer multiple explicit regexes.
-var compiledGiftPatterns = []*regexp.Regexp{
-	// Original forms: emoji/keyword before mention (with word boundaries for text)
-	regexp.MustCompile(`🍺\s*<@[A-Z0-9]+>`),
-	regexp.MustCompile(`🍻\s*<@[A-Z0-9]+>`),
-	regexp.MustCompile(`:beer:\s*<@[A-Z0-9]+>`),
-	regexp.MustCompile(`:beers:\s*<@[A-Z0-9]+>`),
-	regexp.MustCompile(`(?i)\bbeer\s+<@[A-Z0-9]+>`),
-	regexp.MustCompile(`(?i)\bbeers\s+<@[A-Z0-9]+>`),
-
-	// Mention-first ordering with emojis or keywords immediately or with minimal spacing
-	regexp.MustCompile(`<@[A-Z0-9]+>\s*🍺+`),          // one or many single beer emojis
-	regexp.MustCompile(`<@[A-Z0-9]+>\s*🍻+`),          // one or many clinking beer emojis
-	regexp.MustCompile(`<@[A-Z0-9]+>\s*:beer:`),      // textual beer emoji after mention
-	regexp.MustCompile(`<@[A-Z0-9]+>\s*:beers:`),     // textual beers emoji after mention
-	regexp.MustCompile(`(?i)<@[A-Z0-9]+>\s*beer\b`),  // mention then 'beer'
-	regexp.MustCompile(`(?i)<@[A-Z0-9]+>\s*beers\b`), // mention then 'beers'
-
-	// Give/gives/giving/gift phrasing before mention (emoji or keyword after optional quantity with word boundaries)
-	regexp.MustCompile(`(?i)\bgive\s+<@[A-Z0-9]+>\s*(?:\d+\s*)?(?:🍺+|🍻+|:beer:|:beers:|\bbeer\b|\bbeers\b)`),
-	regexp.MustCompile(`(?i)\bgives\s+<@[A-Z0-9]+>\s*(?:\d+\s*)?(?:🍺+|🍻+|:beer:|:beers:|\bbeer\b|\bbeers\b)`),
-	regexp.MustCompile(`(?i)\bgiving\s+<@[A-Z0-9]+>\s*(?:\d+\s*)?(?:🍺+|🍻+|:beer:|:beers:|\bbeer\b|\bbeers\b)`),
-	regexp.MustCompile(`(?i)\bgift\s+<@[A-Z0-9]+>\s*(?:\d+\s*)?(?:🍺+|🍻+|:beer:|:beers:|\bbeer\b|\bbeers\b)`),
-	regexp.MustCompile(`(?i)\bgifting\s+<@[A-Z0-9]+>\s*(?:\d+\s*)?(?:🍺+|🍻+|:beer:|:beers:|\bbeer\b|\bbeers\b)`),
-
-	// Verb after mention: <@U123> gives 3 beers / <@U123> give beer (with word boundaries)
-	regexp.MustCompile(`<@[A-Z0-9]+>\s+(?i:gives?|giving|gift|gifting)\s*(?:\d+\s*)?(?:🍺+|🍻+|:beer:|:beers:|\bbeer\b|\bbeers\b)`),
-}
-
-func (bot *MinimalSlackBot) isBeerGiving(text string) bool {
-	for _, rx := range compiledGiftPatterns {
-		if rx.MatchString(text) {
-			if bot.traceEvents {
-				bot.logger.Debug().Str("pattern", rx.String()).Msg("Beer gift pattern matched")
-			}
-			return true
-		}
+	gift := bot.parseGift(event.Text)
+	if gift == nil {
+		return
+	}
+	if bot.traceEvents {
+		bot.logger.Debug().Int("recipients", len(gift.Recipients)).Str("reason", gift.Reason).Msg("Beer gift parsed")
 	}
-	return false
+	bot.processBeerGiving(ctx, event, envelopeID, gift)
 }
 
-// processBeerGiving handles beer giving events
-func (bot *MinimalSlackBot) processBeerGiving(event *slackevents.MessageEvent, envelopeID string) {
+// processBeerGiving handles a message that parseGift recognized as a beer gift
+func (bot *MinimalSlackBot) processBeerGiving(ctx context.Context, event *slackevents.MessageEvent, envelopeID string, gift *Gift) {
 	// Use the Socket Mode envelope_id for deduplication, fallback to timestamp if not available
 	dedupKey := envelopeID
 	if dedupKey == "" {
@@ -279,115 +517,276 @@ func (bot *MinimalSlackBot) processBeerGiving(event *slackevents.MessageEvent, e
 		bot.logger.Warn().Str("timestamp", event.EventTimeStamp).Msg("No envelope_id available, falling back to timestamp for deduplication")
 	}
 
-	// Check for event deduplication
-	eventTime := parseSlackTS(event.EventTimeStamp)
-	isNewEvent, err := bot.store.TryMarkEventProcessed(dedupKey, eventTime)
+	// Check for event deduplication; the gift's calendar day is the giver's local day
+	eventTime := parseSlackTS(event.EventTimeStamp).In(bot.userLocation(event.User))
+	if !bot.markEventProcessed(ctx, dedupKey, event.User, eventTime) {
+		return
+	}
+
+	delivered := bot.deliverGifts(ctx, giftRequest{
+		dedupKey:  dedupKey,
+		giver:     event.User,
+		channel:   event.Channel,
+		ts:        event.EventTimeStamp,
+		eventTime: eventTime,
+		gifts:     gift.Recipients,
+		note:      storage.BeerNote{ChannelID: event.Channel, Reason: gift.Reason},
+	})
+	if len(delivered) == 0 && ctx.Err() != nil {
+		bot.unmarkEventProcessed(ctx, dedupKey)
+		return
+	}
+	if len(delivered) > 0 {
+		threadTS := ""
+		if isThreadReply(event) {
+			threadTS = event.ThreadTimeStamp
+		}
+		bot.sendBeerConfirmation(ctx, event.Channel, threadTS, event.User, event.EventTimeStamp, delivered, "")
+	}
+}
+
+// isThreadReply reports whether the message is a reply inside a thread (not the parent).
+func isThreadReply(event *slackevents.MessageEvent) bool {
+	return event.ThreadTimeStamp != "" && event.ThreadTimeStamp != event.EventTimeStamp
+}
+
+// allowsThreadGifts reports whether beer messages in threads of this channel count.
+func (bot *MinimalSlackBot) allowsThreadGifts(channel string) bool {
+	return bot.threadGifts || bot.threadChannels[channel]
+}
+
+// defaultRequestTimeout bounds the storage work of one Slack event or API request.
+const defaultRequestTimeout = 10 * time.Second
+
+// requestTimeoutFromEnv reads REQUEST_TIMEOUT, e.g. 5s.
+func requestTimeoutFromEnv() (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv("REQUEST_TIMEOUT"))
+	if v == "" {
+		return defaultRequestTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid REQUEST_TIMEOUT %q", v)
+	}
+	return d, nil
+}
+
+// envOr returns the trimmed environment variable or def when it is unset or blank.
+func envOr(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// parseIDList parses a comma separated list of Slack IDs into a set.
+func parseIDList(v string) map[string]bool {
+	out := map[string]bool{}
+	for _, id := range strings.Split(v, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			out[id] = true
+		}
+	}
+	return out
+}
+
+// markEventProcessed claims dedupKey for this process. It returns false (after
+// recording the outcome) when the event was already handled or the check failed.
+func (bot *MinimalSlackBot) markEventProcessed(ctx context.Context, dedupKey, giver string, eventTime time.Time) bool {
+	isNewEvent, err := bot.store.TryMarkEventProcessed(ctx, dedupKey, eventTime)
 	if err != nil {
-		_ = bot.store.RecordBeerEventOutcome(dedupKey, event.User, "", 0, "error", eventTime)
+		_ = bot.store.RecordBeerEventOutcome(ctx, dedupKey, giver, "", 0, "error", eventTime)
 		bot.logger.Error().
 			Err(err).
-			Str("envelope_id", envelopeID).
-			Str("timestamp", event.EventTimeStamp).
+			Str("dedup_key", dedupKey).
 			Msg("Error checking event deduplication")
 		bot.errorCounter.WithLabelValues("dedup_error").Inc()
-		return
+		return false
 	}
 	if !isNewEvent {
-		_ = bot.store.RecordBeerEventOutcome(dedupKey, event.User, "", 0, "duplicate", eventTime)
+		_ = bot.store.RecordBeerEventOutcome(ctx, dedupKey, giver, "", 0, "duplicate", eventTime)
 		bot.logger.Debug().
-			Str("envelope_id", envelopeID).
-			Str("timestamp", event.EventTimeStamp).
+			Str("dedup_key", dedupKey).
 			Msg("Event already processed, skipping")
 		bot.eventCounter.WithLabelValues("beer_giving", "duplicate").Inc()
-		return
+		return false
 	}
+	return true
+}
 
-	// Extract recipient user ID
-	recipient := bot.extractRecipient(event.Text)
-	if recipient == "" {
-		_ = bot.store.RecordBeerEventOutcome(dedupKey, event.User, "", 0, "invalid_recipient", eventTime)
-		bot.logger.Warn().
-			Str("text", event.Text).
-			Msg("Could not extract recipient from beer message")
-		bot.eventCounter.WithLabelValues("beer_giving", "invalid_recipient").Inc()
-		// Ephemeral feedback
-		bot.postEphemeral(event.Channel, event.User, "⚠️ Could not find a valid recipient in your beer message.")
+// unmarkEventProcessed forgets the dedup mark of a gift event whose ctx ended
+// before any of its beers was written, so a redelivery of it can still give them.
+// Once a row is written the mark stays and a redelivery is a duplicate. ctx is
+// only used for its values; the store call gets a fresh deadline.
+func (bot *MinimalSlackBot) unmarkEventProcessed(ctx context.Context, dedupKey string) {
+	ctx, cancel := bot.requestContext(context.WithoutCancel(ctx))
+	defer cancel()
+	if err := bot.store.UnmarkEventProcessed(ctx, dedupKey); err != nil {
+		bot.logger.Error().
+			Err(err).
+			Str("dedup_key", dedupKey).
+			Msg("Error releasing event deduplication mark")
+		bot.errorCounter.WithLabelValues("dedup_error").Inc()
 		return
 	}
+	bot.logger.Warn().
+		Str("dedup_key", dedupKey).
+		Msg("Event cut short before any beer was stored, a redelivery will be handled again")
+}
 
-	// Extract quantity (default to 1)
-	quantity := bot.extractQuantity(event.Text)
-	if quantity > bot.maxGift {
-		bot.logger.Debug().Int("requested", quantity).Int("capped", bot.maxGift).Msg("Capping beer quantity")
-		quantity = bot.maxGift
-	}
+// giftRequest describes one beer-giving action independent of what triggered it
+// (a message, a reaction, ...). Deduplication happens before it is built.
+type giftRequest struct {
+	dedupKey  string
+	giver     string
+	channel   string    // where feedback is posted
+	ts        string    // Slack ts the beers rows are keyed by
+	synthetic bool      // ts is not a Slack message (e.g. a modal gift), so it has no permalink
+	eventTime time.Time // in the giver's time zone
+	gifts     []recipientGift
+	note      storage.BeerNote // reason, channel and permalink stored with every row
+
+	// status recorded for delivered gifts; "success" when empty
+	status string
+	// budgetCredit is the number of beers already counted today that this request
+	// replaces (e.g. the previous version of an edited message)
+	budgetCredit int
+}
 
-	// Prevent self gifting
-	if recipient == event.User {
-		_ = bot.store.RecordBeerEventOutcome(dedupKey, event.User, recipient, quantity, "self_gift", eventTime)
-		bot.eventCounter.WithLabelValues("beer_giving", "self_gift").Inc()
-		bot.postEphemeral(event.Channel, event.User, "🍺 You can't gift beer to yourself. Find a teammate!")
-		return
-	}
+// deliverGifts applies the per-gift checks (quantity cap, self gifting, daily budget),
+// stores each accepted gift and records one audit outcome per recipient.
+// It returns the gifts that were actually delivered.
+func (bot *MinimalSlackBot) deliverGifts(ctx context.Context, req giftRequest) []recipientGift {
+	// Daily budget: how many beers the giver may still hand out today
+	remaining, err := bot.remainingBudget(ctx, req.giver, req.eventTime, req.budgetCredit)
+	if err != nil {
+		_ = bot.store.RecordBeerEventOutcome(ctx, req.dedupKey, req.giver, "", 0, "error", req.eventTime)
+		bot.logger.Error().
+			Err(err).
+			Str("giver", req.giver).
+			Msg("Failed to check daily beer budget")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return nil
+	}
+	overBudget := false
+	status := req.status
+	if status == "" {
+		status = "success"
+	}
+
+	var delivered []recipientGift
+	for _, gift := range req.gifts {
+		recipient := gift.recipient
+		quantity := gift.quantity
+		if quantity > bot.maxGift {
+			bot.logger.Debug().Int("requested", quantity).Int("capped", bot.maxGift).Msg("Capping beer quantity")
+			quantity = bot.maxGift
+		}
 
-	bot.logger.Info().
-		Str("giver", event.User).
-		Str("recipient", recipient).
-		Int("quantity", quantity).
-		Str("channel", event.Channel).
-		Msg("Processing beer giving")
-
-	if bot.readOnly {
-		bot.logger.Info().Str("mode", "read-only").Msg("Skipping DB write (READ_ONLY enabled)")
-	} else {
-		storeErr := bot.store.AddBeer(event.User, recipient, event.EventTimeStamp, eventTime, quantity)
-		if storeErr != nil {
-			_ = bot.store.RecordBeerEventOutcome(dedupKey, event.User, recipient, quantity, "error", eventTime)
-			bot.logger.Error().
-				Err(storeErr).
-				Str("giver", event.User).
-				Str("recipient", recipient).
-				Int("quantity", quantity).
-				Msg("Failed to store beer transaction")
-			bot.errorCounter.WithLabelValues("storage_error").Inc()
-			return
+		// Prevent self gifting
+		if recipient == req.giver {
+			_ = bot.store.RecordBeerEventOutcome(ctx, req.dedupKey, req.giver, recipient, quantity, "self_gift", req.eventTime)
+			bot.eventCounter.WithLabelValues("beer_giving", "self_gift").Inc()
+			bot.postEphemeral(req.channel, req.giver, "🍺 You can't gift beer to yourself. Find a teammate!")
+			continue
+		}
+
+		if remaining == 0 {
+			_ = bot.store.RecordBeerEventOutcome(ctx, req.dedupKey, req.giver, recipient, quantity, "over_budget", req.eventTime)
+			bot.eventCounter.WithLabelValues("beer_giving", "over_budget").Inc()
+			overBudget = true
+			continue
+		}
+		if remaining > 0 && quantity > remaining {
+			bot.logger.Debug().Int("requested", quantity).Int("remaining", remaining).Msg("Trimming beer quantity to daily budget")
+			quantity = remaining
+			overBudget = true
+		}
+
+		bot.logger.Info().
+			Str("giver", req.giver).
+			Str("recipient", recipient).
+			Int("quantity", quantity).
+			Str("channel", req.channel).
+			Msg("Processing beer giving")
+
+		if bot.readOnly {
+			bot.logger.Info().Str("mode", "read-only").Msg("Skipping DB write (READ_ONLY enabled)")
+		} else {
+			if req.note.ChannelID != "" && req.note.Permalink == "" && !req.synthetic {
+				req.note.Permalink = bot.permalink(req.note.ChannelID, req.ts)
+			}
+			storeErr := bot.store.AddBeer(ctx, req.giver, recipient, req.ts, req.eventTime, quantity, req.note)
+			if storeErr != nil {
+				_ = bot.store.RecordBeerEventOutcome(ctx, req.dedupKey, req.giver, recipient, quantity, "error", req.eventTime)
+				bot.logger.Error().
+					Err(storeErr).
+					Str("giver", req.giver).
+					Str("recipient", recipient).
+					Int("quantity", quantity).
+					Msg("Failed to store beer transaction")
+				bot.errorCounter.WithLabelValues("storage_error").Inc()
+				continue
+			}
+		}
+
+		_ = bot.store.RecordBeerEventOutcome(ctx, req.dedupKey, req.giver, recipient, quantity, status, req.eventTime)
+		bot.eventCounter.WithLabelValues("beer_giving", status).Inc()
+		delivered = append(delivered, recipientGift{recipient: recipient, quantity: quantity})
+		if remaining > 0 {
+			remaining -= quantity
 		}
 	}
 
-	_ = bot.store.RecordBeerEventOutcome(dedupKey, event.User, recipient, quantity, "success", eventTime)
-	bot.eventCounter.WithLabelValues("beer_giving", "success").Inc()
-	bot.sendBeerConfirmation(event.Channel, event.User, recipient, quantity)
+	if overBudget {
+		bot.postEphemeral(req.channel, req.giver, fmt.Sprintf(
+			"🚫 Daily limit of %d beers reached — you have %s left today.", bot.maxPerDay, beerCount(max(remaining, 0))))
+	}
+	// Edits only adjust gifts the recipients were already told about
+	if len(delivered) > 0 && req.status == "" && !bot.readOnly {
+		bot.notifyRecipients(ctx, req, delivered)
+	}
+	if len(delivered) > 0 {
+		users := []string{req.giver}
+		for _, g := range delivered {
+			users = append(users, g.recipient)
+		}
+		bot.refreshHomes(ctx, users...)
+	}
+	return delivered
 }
 
-// extractRecipient extracts the recipient user ID from the message text
-func (bot *MinimalSlackBot) extractRecipient(text string) string {
-	re := regexp.MustCompile(`<@([A-Z0-9]+)>`)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) > 1 {
-		return matches[1]
+// remainingBudget returns how many beers the giver may still give on the day of t,
+// or -1 when no daily limit is configured. credit beers of the day's total are
+// treated as not yet given because the caller is about to replace them.
+func (bot *MinimalSlackBot) remainingBudget(ctx context.Context, giver string, t time.Time, credit int) (int, error) {
+	if bot.maxPerDay <= 0 {
+		return -1, nil
+	}
+	given, err := bot.store.CountGivenOnDate(ctx, giver, t.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
 	}
-	return ""
+	return max(bot.maxPerDay-given+credit, 0), nil
 }
 
-// extractQuantity extracts the beer quantity from the message text
-func (bot *MinimalSlackBot) extractQuantity(text string) int {
-	// Look for numbers in the message
-	re := regexp.MustCompile(`\b(\d+)\b`)
-	matches := re.FindAllString(text, -1)
-
-	for _, match := range matches {
-		if num, err := strconv.Atoi(match); err == nil && num > 0 && num <= 10 {
-			return num
-		}
+// permalink returns the Slack link to a message, or "" when it cannot be resolved.
+func (bot *MinimalSlackBot) permalink(channel, ts string) string {
+	if bot.api == nil || channel == "" || ts == "" {
+		return ""
 	}
-
-	// Count beer emojis
-	beerCount := strings.Count(text, "🍺") + strings.Count(text, "🍻")
-	if beerCount > 0 && beerCount <= 10 {
-		return beerCount
+	link, err := bot.api.GetPermalink(&slack.PermalinkParameters{Channel: channel, Ts: ts})
+	if err != nil {
+		bot.logger.Debug().Err(err).Str("channel", channel).Str("ts", ts).Msg("Failed to resolve message permalink")
+		return ""
 	}
+	return link
+}
 
-	return 1 // Default to 1 beer
+// recipientGift is one recipient's share of a beer message.
+type recipientGift struct {
+	recipient string
+	quantity  int
 }
 
 // parseSlackTS converts a Slack ts (e.g. "1717691574.123456") to time.Time (seconds precision)
@@ -403,31 +802,78 @@ func parseSlackTS(ts string) time.Time {
 	return time.Unix(sec, 0).UTC()
 }
 
-// sendBeerConfirmation sends a confirmation message for beer giving
-func (bot *MinimalSlackBot) sendBeerConfirmation(channel, giver, recipient string, quantity int) {
-	beerEmoji := "🍺"
-	if quantity > 1 {
-		beerEmoji = "🍻"
+// formatSlackTS renders t in Slack's ts format ("1717691574.123456").
+func formatSlackTS(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1000)
+}
+
+// workspaceLocation returns the configured workspace time zone (UTC by default).
+func (bot *MinimalSlackBot) workspaceLocation() *time.Location {
+	if bot.location == nil {
+		return time.UTC
 	}
+	return bot.location
+}
 
-	message := fmt.Sprintf(
-		"%s <@%s> gave %d beer%s to <@%s>!",
-		beerEmoji,
-		giver,
-		quantity,
-		func() string {
-			if quantity == 1 {
-				return ""
-			}
-			return "s"
-		}(),
-		recipient,
-	)
+// userLocation returns the time zone used for a user's calendar days: their Slack
+// profile tz when USER_TZ is enabled and resolvable, otherwise the workspace zone.
+func (bot *MinimalSlackBot) userLocation(user string) *time.Location {
+	fallback := bot.workspaceLocation()
+	if !bot.userTZ || bot.api == nil || user == "" {
+		return fallback
+	}
+
+	bot.tzMu.Lock()
+	cached, ok := bot.userZones[user]
+	bot.tzMu.Unlock()
+	if ok && time.Since(cached.fetched) < userZoneTTL {
+		return cached.loc
+	}
+
+	loc := fallback
+	info, err := bot.api.GetUserInfo(user)
+	if err != nil {
+		bot.logger.Debug().Err(err).Str("user", user).Msg("Failed to look up user time zone, using workspace zone")
+		return fallback
+	}
+	if info.TZ != "" {
+		if l, err := time.LoadLocation(info.TZ); err == nil {
+			loc = l
+		} else {
+			bot.logger.Debug().Err(err).Str("user", user).Str("tz", info.TZ).Msg("Unknown user time zone, using workspace zone")
+		}
+	}
+
+	bot.tzMu.Lock()
+	if bot.userZones == nil {
+		bot.userZones = map[string]cachedZone{}
+	}
+	bot.userZones[user] = cachedZone{loc: loc, fetched: time.Now()}
+	bot.tzMu.Unlock()
+	return loc
+}
+
+// sendBeerConfirmation sends one combined confirmation message for all recipients of a gift,
+// with an Undo button for the giver, and remembers where it was posted. giftTS is the
+// ts the gift is stored under; a non-empty reason is quoted below the confirmation.
+// A non-empty threadTS posts the confirmation into that thread (and, with THREAD_BROADCAST, also to the channel).
+func (bot *MinimalSlackBot) sendBeerConfirmation(ctx context.Context, channel, threadTS, giver, giftTS string, gifts []recipientGift, reason string) {
+	message := formatBeerConfirmation(giver, gifts)
+	if reason != "" {
+		message += "\n> " + reason
+	}
 
-	_, _, err := bot.api.PostMessage(
-		channel,
+	opts := []slack.MsgOption{
 		slack.MsgOptionText(message, false),
-	)
+		slack.MsgOptionBlocks(bot.confirmationBlocks(message, giver, giftTS)...),
+	}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+		if bot.threadBroadcast {
+			opts = append(opts, slack.MsgOptionBroadcast())
+		}
+	}
+	postedChannel, postedTS, err := bot.api.PostMessage(channel, opts...)
 
 	if err != nil {
 		bot.logger.Error().
@@ -435,81 +881,120 @@ func (bot *MinimalSlackBot) sendBeerConfirmation(channel, giver, recipient strin
 			Str("channel", channel).
 			Msg("Failed to send confirmation message")
 		bot.errorCounter.WithLabelValues("message_error").Inc()
-	} else {
-		bot.logger.Debug().
-			Str("channel", channel).
-			Str("message", message).
-			Msg("Sent beer confirmation message")
+		return
+	}
+	bot.logger.Debug().
+		Str("channel", channel).
+		Str("message", message).
+		Msg("Sent beer confirmation message")
+	if !bot.readOnly {
+		if err := bot.store.SetConfirmation(ctx, giver, giftTS, postedChannel, postedTS); err != nil {
+			bot.logger.Warn().Err(err).Str("giver", giver).Str("ts", giftTS).Msg("Failed to remember confirmation message")
+		}
 	}
 }
 
-// postEphemeral sends an ephemeral message (best-effort, logs errors only).
-func (bot *MinimalSlackBot) postEphemeral(channel, user, text string) {
-	if channel == "" || user == "" {
-		return
+// formatBeerConfirmation renders the confirmation text, e.g.
+// "🍻 <@G> gave 2 beers each to <@A> and <@B>!" or
+// "🍻 <@G> gave 2 beers to <@A> and 1 beer to <@B>!".
+func formatBeerConfirmation(giver string, gifts []recipientGift) string {
+	total := 0
+	for _, g := range gifts {
+		total += g.quantity
 	}
-	_, err := bot.api.PostEphemeral(channel, user, slack.MsgOptionText(text, false))
-	if err != nil {
-		bot.logger.Debug().Err(err).Msg("Failed to post ephemeral message")
+	beerEmoji := "🍺"
+	if total > 1 {
+		beerEmoji = "🍻"
 	}
+	return fmt.Sprintf("%s <@%s> gave %s!", beerEmoji, giver, formatGiftList(gifts))
 }
 
-// handleSlashCommand processes slash commands (e.g., /beer-stats)
-func (bot *MinimalSlackBot) handleSlashCommand(cmd slack.SlashCommand) {
-	// Only support /beer-stats for now
-	if cmd.Command != "/beer-stats" {
-		bot.api.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText("Unsupported command.", false))
-		return
+// formatGiftList renders who got how many beers, e.g. "2 beers each to <@A> and <@B>"
+// or "2 beers to <@A> and 1 beer to <@B>".
+func formatGiftList(gifts []recipientGift) string {
+	sameQuantity := true
+	for _, g := range gifts {
+		if g.quantity != gifts[0].quantity {
+			sameQuantity = false
+		}
 	}
-	// Parse optional args: timeframe=7 limit=5
-	days := 7
-	limit := 5
-	parts := strings.Fields(cmd.Text)
-	for _, p := range parts {
-		kv := strings.SplitN(p, "=", 2)
-		if len(kv) != 2 {
-			continue
+	if sameQuantity {
+		mentions := make([]string, 0, len(gifts))
+		for _, g := range gifts {
+			mentions = append(mentions, fmt.Sprintf("<@%s>", g.recipient))
 		}
-		switch strings.ToLower(kv[0]) {
-		case "timeframe", "days":
-			if n, err := strconv.Atoi(kv[1]); err == nil && n > 0 && n <= 365 {
-				days = n
-			}
-		case "limit":
-			if n, err := strconv.Atoi(kv[1]); err == nil && n > 0 && n <= 25 {
-				limit = n
-			}
+		each := ""
+		if len(gifts) > 1 {
+			each = " each"
 		}
+		return fmt.Sprintf("%s%s to %s", beerCount(gifts[0].quantity), each, joinWithAnd(mentions))
 	}
-	end := time.Now()
-	start := end.AddDate(0, 0, -days)
-	givers, gErr := bot.store.TopGivers(start, end, limit)
-	receivers, rErr := bot.store.TopReceivers(start, end, limit)
+	parts := make([]string, 0, len(gifts))
+	for _, g := range gifts {
+		parts = append(parts, fmt.Sprintf("%s to <@%s>", beerCount(g.quantity), g.recipient))
+	}
+	return joinWithAnd(parts)
+}
 
-	if gErr != nil || rErr != nil {
-		bot.api.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText("Error generating stats.", false))
-		return
+// beerCount renders "1 beer" / "n beers".
+func beerCount(n int) string {
+	if n == 1 {
+		return "1 beer"
 	}
+	return fmt.Sprintf("%d beers", n)
+}
 
-	var b strings.Builder
-	b.WriteString(fmt.Sprintf("*Beer Stats* (last %d days)\n", days))
-	b.WriteString("*Top Givers:*\n")
-	if len(givers) == 0 {
-		b.WriteString("(none)\n")
-	} else {
-		for i, row := range givers {
-			b.WriteString(fmt.Sprintf("%d. <@%s> — %s\n", i+1, row[0], row[1]))
-		}
+// giverMention renders a gift's giver, naming admin grants instead of
+// mentioning their system giver.
+func giverMention(giver string) string {
+	if giver == storage.GrantGiver {
+		return "Admin grant"
 	}
-	b.WriteString("*Top Receivers:*\n")
-	if len(receivers) == 0 {
-		b.WriteString("(none)\n")
-	} else {
-		for i, row := range receivers {
-			b.WriteString(fmt.Sprintf("%d. <@%s> — %s\n", i+1, row[0], row[1]))
-		}
+	return "<@" + giver + ">"
+}
+
+// joinWithAnd joins items as "a", "a and b" or "a, b and c".
+func joinWithAnd(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// postEphemeral sends an ephemeral message (best-effort, logs errors only).
+func (bot *MinimalSlackBot) postEphemeral(channel, user, text string) {
+	if channel == "" || user == "" {
+		return
+	}
+	_, err := bot.api.PostEphemeral(channel, user, slack.MsgOptionText(text, false))
+	if err != nil {
+		bot.logger.Debug().Err(err).Msg("Failed to post ephemeral message")
+	}
+}
+
+// handleSlashCommand dispatches slash commands (e.g., /beer-stats, /beer-admin)
+func (bot *MinimalSlackBot) handleSlashCommand(ctx context.Context, cmd slack.SlashCommand) {
+	switch cmd.Command {
+	case "/beer-stats":
+		bot.handleBeerStats(ctx, cmd)
+	case "/beer-admin":
+		bot.handleBeerAdmin(ctx, cmd)
+	case "/beer-give":
+		bot.handleBeerGive(cmd)
+	case "/beer-undo":
+		bot.handleBeerUndo(ctx, cmd)
+	case "/beer-me":
+		bot.handleBeerMe(ctx, cmd)
+	case "/beer-help":
+		bot.handleBeerHelp(cmd)
+	case "/beer-settings":
+		bot.handleBeerSettings(ctx, cmd)
+	default:
+		bot.api.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText("Unsupported command.", false))
 	}
-	bot.api.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText(b.String(), false))
 }
 
 // TestConnection verifies the Slack connection and bot info
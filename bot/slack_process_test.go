@@ -1,62 +1,524 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
 )
 
-// mockStore implements Store for testing processBeerGiving logic
-type mockStore struct{ outcomes []string }
-
-func (m *mockStore) CountGivenInDateRange(user string, start, end time.Time) (int, error) {
-	return 0, nil
-}
-func (m *mockStore) CountReceivedInDateRange(user string, start, end time.Time) (int, error) {
-	return 0, nil
-}
-func (m *mockStore) CountGivenOnDate(user string, date string) (int, error) { return 0, nil }
-func (m *mockStore) GetAllGivers() ([]string, error)                        { return nil, nil }
-func (m *mockStore) GetAllRecipients() ([]string, error)                    { return nil, nil }
-func (m *mockStore) TryMarkEventProcessed(eventID string, t time.Time) (bool, error) {
-	// Return true to indicate this is a NEW event (not already processed)
-	// This allows the test to proceed through the processing logic
-	return true, nil
-}
-func (m *mockStore) AddBeer(giver string, recipient string, ts string, eventTime time.Time, count int) error {
-	return nil
+// received sums the gifts still on record in s per recipient.
+func received(t *testing.T, s storage.Store) map[string]int {
+	t.Helper()
+	ctx := t.Context()
+	rows, err := s.RecentBeers(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("recent beers: %v", err)
+	}
+	out := map[string]int{}
+	for _, r := range rows {
+		out[r.Recipient] += r.Count
+	}
+	return out
 }
-func (m *mockStore) RecordBeerEventOutcome(eventID, giverID, recipientID string, quantity int, status string, t time.Time) error {
-	m.outcomes = append(m.outcomes, status)
-	return nil
+
+// giftTo returns the newest gift on record for recipient.
+func giftTo(t *testing.T, s storage.Store, recipient string) storage.BeerRecord {
+	t.Helper()
+	ctx := t.Context()
+	rows, err := s.RecentReceived(ctx, recipient, 1)
+	if err != nil || len(rows) == 0 {
+		t.Fatalf("expected a gift to %s, got %v (err %v)", recipient, rows, err)
+	}
+	return rows[0]
 }
-func (m *mockStore) TopGivers(start, end time.Time, limit int) ([][2]string, error) { return nil, nil }
-func (m *mockStore) TopReceivers(start, end time.Time, limit int) ([][2]string, error) {
-	return nil, nil
+
+// statuses returns the outcome statuses recorded in s, oldest first.
+func statuses(s *storage.MemoryStore) []string {
+	var out []string
+	for _, o := range s.Outcomes() {
+		out = append(out, o.Status)
+	}
+	return out
 }
 
 func TestProcessBeerGiving_SelfGift(t *testing.T) {
-	ms := &mockStore{}
-	eventCounter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_events", Help: ""}, []string{"type", "status"})
-	errorCounter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_errors", Help: ""}, []string{"type"})
-	// Provide bot without Slack client; use empty channel so postEphemeral is skipped (avoids nil deref)
-	bot := &MinimalSlackBot{store: ms, maxGift: 10, eventCounter: eventCounter, errorCounter: errorCounter}
-	// message giving beer to self should trigger self_gift outcome; ensure pattern matches isBeerGiving
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms)
+	// message giving beer to self should trigger self_gift outcome; ensure parseGift recognizes it.
+	// The empty channel skips the ephemeral reply.
 	ev := &slackevents.MessageEvent{Text: "🍺 <@USELF>", User: "USELF", Channel: "", EventTimeStamp: "1717691574.000000"}
-	if !bot.isBeerGiving(ev.Text) {
+	gift := bot.parseGift(ev.Text)
+	if gift == nil {
 		t.Fatalf("test precondition failed: text not recognized as beer giving")
 	}
 	// Call logic directly with test envelope_id; ignore ephemeral post errors (stub client)
-	bot.processBeerGiving(ev, "test-envelope-123")
+	bot.processBeerGiving(ctx, ev, "test-envelope-123", gift)
 	found := false
-	for _, status := range ms.outcomes {
+	for _, status := range statuses(ms) {
 		if status == "self_gift" {
 			found = true
 		}
 	}
 	if !found {
-		t.Fatalf("expected self_gift status recorded, outcomes=%v", ms.outcomes)
+		t.Fatalf("expected self_gift status recorded, outcomes=%v", statuses(ms))
+	}
+}
+
+// newTestBot returns a bot over store with a stub Slack API, throwaway metrics and
+// a per-gift limit of 10; opts adjust any other field.
+func newTestBot(t *testing.T, store storage.Store, opts ...func(*MinimalSlackBot)) *MinimalSlackBot {
+	t.Helper()
+	bot := &MinimalSlackBot{
+		api:          newTestSlackAPI(t),
+		store:        store,
+		maxGift:      10,
+		eventCounter: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_events", Help: ""}, []string{"type", "status"}),
+		errorCounter: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_errors", Help: ""}, []string{"type"}),
+	}
+	for _, opt := range opts {
+		opt(bot)
+	}
+	return bot
+}
+
+// newTestSlackAPI returns a Slack client backed by a stub server that answers ok to every call.
+func newTestSlackAPI(t *testing.T) *slack.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1717691574.000100"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+}
+
+func TestProcessBeerGiving_MultipleRecipients(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms)
+	ev := &slackevents.MessageEvent{Text: "🍺🍺 <@UA> <@UB> <@UGIVER>", User: "UGIVER", Channel: "C1", EventTimeStamp: "1717691574.000000"}
+	bot.processBeerGiving(ctx, ev, "test-envelope-multi", bot.parseGift(ev.Text))
+
+	beers := received(t, ms)
+	if beers["UA"] != 2 || beers["UB"] != 2 {
+		t.Fatalf("expected 2 beers each for UA and UB, got %v", beers)
+	}
+	if _, ok := beers["UGIVER"]; ok {
+		t.Fatalf("self gift must not be stored, got %v", beers)
+	}
+	want := []string{"success", "success", "self_gift"}
+	if got := statuses(ms); !slices.Equal(got, want) {
+		t.Fatalf("expected outcomes %v, got %v", want, got)
+	}
+}
+
+func TestProcessBeerGiving_StoresReason(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms)
+	ev := &slackevents.MessageEvent{Text: "<@UA> 🍺 for fixing the prod outage", User: "UGIVER", Channel: "C1", EventTimeStamp: "1717691574.000000"}
+	bot.processBeerGiving(ctx, ev, "test-envelope-reason", bot.parseGift(ev.Text))
+
+	gift := giftTo(t, ms, "UA")
+	if gift.Reason != "for fixing the prod outage" || gift.ChannelID != "C1" {
+		t.Fatalf("unexpected gift %+v", gift)
+	}
+}
+
+func TestProcessBeerGiving_DailyBudget(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.maxPerDay = 10 })
+	ev := &slackevents.MessageEvent{Text: "🍺🍺🍺 <@UA> <@UB>", User: "UGIVER", Channel: "C1", EventTimeStamp: "1717691574.000000"}
+	_ = ms.AddBeer(ctx, "UGIVER", "UC", "1717690000.000000", parseSlackTS(ev.EventTimeStamp), 8, storage.BeerNote{})
+	bot.processBeerGiving(ctx, ev, "test-envelope-budget", bot.parseGift(ev.Text))
+
+	// 2 beers left: UA is trimmed from 3 to 2, UB is rejected
+	beers := received(t, ms)
+	if beers["UA"] != 2 {
+		t.Fatalf("expected UA trimmed to 2 beers, got %v", beers)
+	}
+	if _, ok := beers["UB"]; ok {
+		t.Fatalf("expected UB rejected, got %v", beers)
+	}
+	if got := statuses(ms); !slices.Equal(got, []string{"success", "over_budget"}) {
+		t.Fatalf("expected [success over_budget], got %v", got)
+	}
+}
+
+func TestProcessBeerGiving_CanceledContext(t *testing.T) {
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms)
+	ev := &slackevents.MessageEvent{Text: "🍺 <@UA>", User: "UGIVER", Channel: "C1", EventTimeStamp: "1717691574.000000"}
+
+	// an event whose context is already canceled stores nothing, not even its dedup mark
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	bot.processBeerGiving(ctx, ev, "env-retried", bot.parseGift(ev.Text))
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("expected no gift for a canceled event, got %v", beers)
+	}
+
+	// so handling the same envelope again delivers the gift
+	bot.processBeerGiving(t.Context(), ev, "env-retried", bot.parseGift(ev.Text))
+	if beers := received(t, ms); beers["UA"] != 1 {
+		t.Fatalf("expected the retry to deliver the gift, got %v", beers)
+	}
+}
+
+// stallingAddBeer lets the first after AddBeer calls through and holds the next one
+// until its context ends, like a database that outlives REQUEST_TIMEOUT.
+type stallingAddBeer struct {
+	storage.Store
+	after   int
+	stalled bool
+}
+
+func (s *stallingAddBeer) AddBeer(ctx context.Context, giver, recipient, ts string, eventTime time.Time, count int, note storage.BeerNote) error {
+	if s.after == 0 && !s.stalled {
+		s.stalled = true
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	s.after--
+	return s.Store.AddBeer(ctx, giver, recipient, ts, eventTime, count, note)
+}
+
+// messageEnvelope wraps a beer message in a Socket Mode Events API envelope.
+func messageEnvelope(envelopeID, text string) socketmode.Event {
+	return socketmode.Event{
+		Type: socketmode.EventTypeEventsAPI,
+		Data: slackevents.EventsAPIEvent{
+			Type: slackevents.CallbackEvent,
+			InnerEvent: slackevents.EventsAPIInnerEvent{
+				Type: "message",
+				Data: &slackevents.MessageEvent{Text: text, User: "UGIVER", Channel: "C1", EventTimeStamp: "1717691574.000000"},
+			},
+		},
+		Request: &socketmode.Request{EnvelopeID: envelopeID},
+	}
+}
+
+func TestProcessEvent_DeadlineAfterMark(t *testing.T) {
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, &stallingAddBeer{Store: ms}, func(b *MinimalSlackBot) { b.requestTimeout = 20 * time.Millisecond })
+	evt := messageEnvelope("env-timeout", "🍺 <@UA>")
+
+	// the deadline passes after the event was marked, while the gift is stored
+	bot.processEvent(t.Context(), evt)
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("expected no gift for a timed out event, got %v", beers)
+	}
+
+	// nothing was written, so a redelivery is not taken for a duplicate
+	bot.requestTimeout = 0
+	bot.processEvent(t.Context(), evt)
+	if beers := received(t, ms); beers["UA"] != 1 {
+		t.Fatalf("expected the redelivery to deliver the gift, got %v", beers)
+	}
+	if got := statuses(ms); !slices.Equal(got, []string{"success"}) {
+		t.Fatalf("expected [success], got %v", got)
+	}
+}
+
+func TestProcessEvent_DeadlineAfterPartialGift(t *testing.T) {
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, &stallingAddBeer{Store: ms, after: 1}, func(b *MinimalSlackBot) { b.requestTimeout = 20 * time.Millisecond })
+	evt := messageEnvelope("env-partial", "🍺 <@UA> <@UB>")
+
+	bot.processEvent(t.Context(), evt)
+	if beers := received(t, ms); beers["UA"] != 1 || len(beers) != 1 {
+		t.Fatalf("expected only UA's beer stored, got %v", beers)
+	}
+
+	// UA's beer is on record, so the mark stays and a redelivery gives nothing twice
+	bot.requestTimeout = 0
+	bot.processEvent(t.Context(), evt)
+	if beers := received(t, ms); beers["UA"] != 1 || len(beers) != 1 {
+		t.Fatalf("expected the redelivery to be skipped, got %v", beers)
+	}
+	if got := statuses(ms); !slices.Equal(got, []string{"success", "duplicate"}) {
+		t.Fatalf("expected [success duplicate], got %v", got)
+	}
+}
+
+// blockingAddBeer reports each AddBeer call on started and holds it until release
+// is closed.
+type blockingAddBeer struct {
+	storage.Store
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingAddBeer) AddBeer(ctx context.Context, giver, recipient, ts string, eventTime time.Time, count int, note storage.BeerNote) error {
+	s.started <- struct{}{}
+	<-s.release
+	return s.Store.AddBeer(ctx, giver, recipient, ts, eventTime, count, note)
+}
+
+func TestHandleEvents_FinishesTakenEventsOnStop(t *testing.T) {
+	ms := storage.NewMemoryStore()
+	store := &blockingAddBeer{Store: ms, started: make(chan struct{}, 1), release: make(chan struct{})}
+	bot := newTestBot(t, store)
+	bot.stop = make(chan struct{})
+	bot.client = socketmode.New(bot.api)
+	bot.running.Go(func() { bot.handleEvents(t.Context()) })
+
+	bot.client.Events <- messageEnvelope("env-queued", "🍺 <@UA>")
+	<-store.started
+	// the event was ACKed when it was taken, so it must not be dropped on shutdown
+	go func() {
+		<-bot.stop
+		close(store.release)
+	}()
+	if err := bot.Stop(t.Context()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if beers := received(t, ms); beers["UA"] != 1 {
+		t.Fatalf("expected the taken event to be handled, got %v", beers)
+	}
+}
+
+func TestReactionGifts(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.reactions = parseReactionList("beer,:prost:") })
+	item := slackevents.Item{Type: "message", Channel: "C1", Timestamp: "1717691500.000100"}
+
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "prost", Item: item, EventTimestamp: "1717691574.000000"}, "env-add")
+	if beers := received(t, ms); beers["UA"] != 1 {
+		t.Fatalf("expected 1 beer for message author, got %v", beers)
+	}
+
+	// reactions outside the configured set are ignored
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UGIVER", ItemUser: "UB", Reaction: "tada", Item: item, EventTimestamp: "1717691575.000000"}, "env-other")
+	if beers := received(t, ms); beers["UB"] != 0 {
+		t.Fatalf("unexpected beer for non-beer reaction: %v", beers)
+	}
+
+	// reacting to your own message is ignored without a reply
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UA", ItemUser: "UA", Reaction: "beer", Item: item, EventTimestamp: "1717691576.000000"}, "env-self")
+	if got := statuses(ms); !slices.Equal(got, []string{"success"}) {
+		t.Fatalf("expected the self reaction ignored, got %v", got)
+	}
+
+	bot.handleReactionRemoved(ctx, &slackevents.ReactionRemovedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "prost", Item: item, EventTimestamp: "1717691577.000000"}, "env-remove")
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("expected reaction beer reversed, got %v", beers)
+	}
+	if got := statuses(ms); got[len(got)-1] != "revoked" {
+		t.Fatalf("expected revoked outcome, got %v", got)
+	}
+}
+
+func TestReactionRemoved_KeepsRevokedRow(t *testing.T) {
+	ctx := t.Context()
+	s, revoked := openRevocationStore(t)
+	bot := newTestBot(t, s, func(b *MinimalSlackBot) { b.reactions = parseReactionList("beer") })
+	item := slackevents.Item{Type: "message", Channel: "C1", Timestamp: "1717691500.000100"}
+
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "beer", Item: item, EventTimestamp: "1717691574.000000"}, "env-add")
+	bot.handleReactionRemoved(ctx, &slackevents.ReactionRemovedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "beer", Item: item, EventTimestamp: "1717691577.000000"}, "env-remove")
+	if !revoked("UGIVER", "UA", item.Timestamp) || received(t, s)["UA"] != 0 {
+		t.Fatalf("expected the reaction gift revoked and kept on record, got %v", received(t, s))
+	}
+
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "beer", Item: item, EventTimestamp: "1717691580.000000"}, "env-readd")
+	if revoked("UGIVER", "UA", item.Timestamp) || received(t, s)["UA"] != 1 {
+		t.Fatalf("expected reacting again to restore the gift, got %v", received(t, s))
+	}
+}
+
+func TestReactionRemoved_OnlyRevokesActiveGifts(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) {
+		b.reactions = parseReactionList("beer")
+		b.maxPerDay = 1
+	})
+	first := slackevents.Item{Type: "message", Channel: "C1", Timestamp: "1717691500.000100"}
+	second := slackevents.Item{Type: "message", Channel: "C1", Timestamp: "1717691501.000100"}
+
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "beer", Item: first, EventTimestamp: "1717691574.000000"}, "env-add-1")
+	bot.handleReactionAdded(ctx, &slackevents.ReactionAddedEvent{User: "UGIVER", ItemUser: "UB", Reaction: "beer", Item: second, EventTimestamp: "1717691575.000000"}, "env-add-2")
+	if got := statuses(ms); !slices.Equal(got, []string{"success", "over_budget"}) {
+		t.Fatalf("expected the second reaction over budget, got %v", got)
+	}
+
+	// removing the rejected reaction, or a reaction already removed, changes nothing
+	bot.handleReactionRemoved(ctx, &slackevents.ReactionRemovedEvent{User: "UGIVER", ItemUser: "UB", Reaction: "beer", Item: second, EventTimestamp: "1717691576.000000"}, "env-remove-2")
+	bot.handleReactionRemoved(ctx, &slackevents.ReactionRemovedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "beer", Item: first, EventTimestamp: "1717691577.000000"}, "env-remove-1")
+	bot.handleReactionRemoved(ctx, &slackevents.ReactionRemovedEvent{User: "UGIVER", ItemUser: "UA", Reaction: "beer", Item: first, EventTimestamp: "1717691578.000000"}, "env-remove-1-again")
+	if got := statuses(ms); !slices.Equal(got, []string{"success", "over_budget", "revoked"}) {
+		t.Fatalf("expected a single revoked outcome, got %v", got)
+	}
+}
+
+func TestMessageEditAndDelete(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms)
+	ts := "1717691574.000000"
+	ev := &slackevents.MessageEvent{Text: "🍺 <@UA>", User: "UGIVER", Channel: "C1", EventTimeStamp: ts}
+	bot.processBeerGiving(ctx, ev, "env-original", bot.parseGift(ev.Text))
+
+	edit := &slackevents.MessageEvent{
+		SubType:        "message_changed",
+		Channel:        "C1",
+		EventTimeStamp: "1717691600.000000",
+		Message:        &slack.Msg{User: "UGIVER", Text: "🍺🍺🍺 <@UB>", Timestamp: ts},
+	}
+	bot.handleMessage(ctx, edit, "env-edit")
+	beers := received(t, ms)
+	if _, ok := beers["UA"]; ok {
+		t.Fatalf("expected UA's beer revoked after edit, got %v", beers)
+	}
+	if beers["UB"] != 3 {
+		t.Fatalf("expected UB to get 3 beers after edit, got %v", beers)
+	}
+	if got := statuses(ms); !slices.Equal(got[len(got)-2:], []string{"revoked", "amended"}) {
+		t.Fatalf("expected [revoked amended] outcomes, got %v", got)
+	}
+
+	del := &slackevents.MessageEvent{
+		SubType:          "message_deleted",
+		Channel:          "C1",
+		DeletedTimeStamp: ts,
+		PreviousMessage:  &slack.Msg{User: "UGIVER", Text: "🍺🍺🍺 <@UB>", Timestamp: ts},
+	}
+	bot.handleMessage(ctx, del, "env-delete")
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("expected all gifts removed after delete, got %v", beers)
+	}
+	if got := statuses(ms); got[len(got)-1] != "revoked" {
+		t.Fatalf("expected revoked outcome for delete, got %v", got)
+	}
+}
+
+func TestMessageEdit_UnchangedGift(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.maxPerDay = 3 })
+	ts := "1717691574.000000"
+	text := "🍺🍺 <@UA> <@UB> <@UC> <@UGIVER>"
+	ev := &slackevents.MessageEvent{Text: text, User: "UGIVER", Channel: "C1", EventTimeStamp: ts}
+	bot.processBeerGiving(ctx, ev, "env-original", bot.parseGift(ev.Text))
+	want := statuses(ms)
+	if !slices.Equal(want, []string{"success", "success", "over_budget", "self_gift"}) {
+		t.Fatalf("unexpected outcomes for the original message: %v", want)
+	}
+
+	// a link unfurl resends the same text; a typo fix leaves the gift alone
+	for i, newText := range []string{text, text + " cheers!"} {
+		bot.handleMessage(ctx, &slackevents.MessageEvent{
+			SubType:         "message_changed",
+			Channel:         "C1",
+			EventTimeStamp:  fmt.Sprintf("17176916%02d.000000", i),
+			Message:         &slack.Msg{User: "UGIVER", Text: newText, Timestamp: ts},
+			PreviousMessage: &slack.Msg{User: "UGIVER", Text: text, Timestamp: ts},
+		}, fmt.Sprintf("env-edit-%d", i))
+	}
+	if got := statuses(ms); !slices.Equal(got, want) {
+		t.Fatalf("expected no outcomes for edits that keep the gift, got %v", got)
+	}
+	if beers := received(t, ms); beers["UA"] != 2 || beers["UB"] != 1 || len(beers) != 2 {
+		t.Fatalf("expected the gift untouched, got %v", beers)
+	}
+}
+
+// openRevocationStore returns a SQLite store and a function reporting whether
+// the gift's row is still on record with revoked_at set.
+func openRevocationStore(t *testing.T) (storage.Store, func(giver, recipient, ts string) bool) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "revoke.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := storage.NewSQLiteStore(t.Context(), db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	return s, func(giver, recipient, ts string) bool {
+		var revokedAt sql.NullString
+		err := db.QueryRow(`SELECT revoked_at FROM beers WHERE giver_id = ? AND recipient_id = ? AND ts = ?`, giver, recipient, ts).Scan(&revokedAt)
+		return err == nil && revokedAt.Valid
+	}
+}
+
+func TestMessageEditAndDelete_KeepRevokedRows(t *testing.T) {
+	ctx := t.Context()
+	s, revoked := openRevocationStore(t)
+	bot := newTestBot(t, s)
+	ts := "1717691574.000000"
+	ev := &slackevents.MessageEvent{Text: "🍺 <@UA> 🍺 <@UB>", User: "UGIVER", Channel: "C1", EventTimeStamp: ts}
+	bot.processBeerGiving(ctx, ev, "env-original", bot.parseGift(ev.Text))
+
+	bot.handleMessage(ctx, &slackevents.MessageEvent{
+		SubType:        "message_changed",
+		Channel:        "C1",
+		EventTimeStamp: "1717691600.000000",
+		Message:        &slack.Msg{User: "UGIVER", Text: "🍺 <@UB>", Timestamp: ts},
+	}, "env-edit")
+	if !revoked("UGIVER", "UA", ts) || revoked("UGIVER", "UB", ts) {
+		t.Fatalf("expected only UA's gift revoked and kept on record")
+	}
+
+	bot.handleMessage(ctx, &slackevents.MessageEvent{
+		SubType:          "message_deleted",
+		Channel:          "C1",
+		DeletedTimeStamp: ts,
+		PreviousMessage:  &slack.Msg{User: "UGIVER", Text: "🍺 <@UB>", Timestamp: ts},
+	}, "env-delete")
+	if !revoked("UGIVER", "UB", ts) {
+		t.Fatalf("expected the deleted message's gift revoked and kept on record")
+	}
+	if beers := received(t, s); len(beers) != 0 {
+		t.Fatalf("expected no gifts counted after delete, got %v", beers)
+	}
+}
+
+func TestThreadGifts(t *testing.T) {
+	ctx := t.Context()
+	var threadTS string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chat.postMessage" {
+			_ = r.ParseForm()
+			threadTS = r.PostForm.Get("thread_ts")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1717691574.000100"}`))
+	}))
+	defer srv.Close()
+
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.api = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/")) })
+	reply := &slackevents.MessageEvent{Text: "🍺 <@UA>", User: "UGIVER", Channel: "C1", ThreadTimeStamp: "1717691000.000000", EventTimeStamp: "1717691574.000000"}
+
+	bot.handleMessage(ctx, reply, "env-thread-off")
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("thread reply must be ignored by default, got %v", beers)
+	}
+
+	bot.threadChannels = parseIDList("C1")
+	bot.handleMessage(ctx, reply, "env-thread-on")
+	if beers := received(t, ms); beers["UA"] != 1 {
+		t.Fatalf("expected thread gift recorded, got %v", beers)
+	}
+	if threadTS != "1717691000.000000" {
+		t.Fatalf("expected confirmation in thread, got thread_ts=%q", threadTS)
 	}
 }
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/slack-go/slack"
 )
 
 func TestParseSlackTS_Valid(t *testing.T) {
@@ -23,26 +29,93 @@ func TestParseSlackTS_Invalid(t *testing.T) {
 	}
 }
 
-func TestExtractQuantity_Number(t *testing.T) {
+func TestParseGift_Quantity(t *testing.T) {
 	bot := &MinimalSlackBot{}
-	q := bot.extractQuantity("I give 5 beers to <@U123>")
-	if q != 5 {
-		t.Fatalf("expected 5 got %d", q)
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"I give 5 beers to <@U123>", 5}, // explicit number
+		{"🍺🍺 <@U123>", 2},                // one beer per emoji
+		{"beer <@U123>", 1},              // bare keyword
+	}
+	for _, c := range cases {
+		gift := bot.parseGift(c.text)
+		if gift == nil || len(gift.Recipients) != 1 {
+			t.Fatalf("%q: expected one recipient got %+v", c.text, gift)
+		}
+		if q := gift.Recipients[0].quantity; q != c.want {
+			t.Fatalf("%q: expected %d got %d", c.text, c.want, q)
+		}
 	}
 }
 
-func TestExtractQuantity_EmojiCount(t *testing.T) {
+func TestParseGift_PerRecipientQuantity(t *testing.T) {
 	bot := &MinimalSlackBot{}
-	q := bot.extractQuantity("🍺🍺 <@U123>")
-	if q != 2 {
-		t.Fatalf("expected 2 got %d", q)
+	gift := bot.parseGift("<@UA> 🍺🍺 <@UB> 🍺 <@UA>")
+	if gift == nil || len(gift.Recipients) != 2 {
+		t.Fatalf("expected 2 recipients got %+v", gift)
+	}
+	gifts := gift.Recipients
+	if gifts[0] != (recipientGift{recipient: "UA", quantity: 2}) || gifts[1] != (recipientGift{recipient: "UB", quantity: 1}) {
+		t.Fatalf("unexpected gifts %v", gifts)
 	}
 }
 
-func TestExtractQuantity_Default(t *testing.T) {
-	bot := &MinimalSlackBot{}
-	q := bot.extractQuantity("beer <@U123>")
-	if q != 1 {
-		t.Fatalf("expected 1 got %d", q)
+func TestFormatBeerConfirmation(t *testing.T) {
+	cases := []struct {
+		gifts []recipientGift
+		want  string
+	}{
+		{[]recipientGift{{"UA", 1}}, "🍺 <@UG> gave 1 beer to <@UA>!"},
+		{[]recipientGift{{"UA", 2}, {"UB", 2}}, "🍻 <@UG> gave 2 beers each to <@UA> and <@UB>!"},
+		{[]recipientGift{{"UA", 2}, {"UB", 1}, {"UC", 1}}, "🍻 <@UG> gave 2 beers to <@UA>, 1 beer to <@UB> and 1 beer to <@UC>!"},
+	}
+	for _, c := range cases {
+		if got := formatBeerConfirmation("UG", c.gifts); got != c.want {
+			t.Fatalf("expected %q got %q", c.want, got)
+		}
+	}
+}
+
+func TestUserLocation_SlackProfileTZ(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"user":{"id":"U1","tz":"America/New_York"}}`))
+	}))
+	defer srv.Close()
+
+	bot := &MinimalSlackBot{api: slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/")), location: time.UTC, userTZ: true}
+	if got := bot.userLocation("U1").String(); got != "America/New_York" {
+		t.Fatalf("expected America/New_York got %s", got)
+	}
+
+	bot.userTZ = false
+	if got := bot.userLocation("U1"); got != time.UTC {
+		t.Fatalf("expected workspace zone when USER_TZ is off, got %s", got)
+	}
+}
+
+func TestStop_WaitsForRunningWork(t *testing.T) {
+	bot := &MinimalSlackBot{stop: make(chan struct{})}
+	finished := false
+	bot.running.Go(func() {
+		<-bot.stop
+		time.Sleep(10 * time.Millisecond) // the event being handled
+		finished = true
+	})
+	if err := bot.Stop(t.Context()); err != nil || !finished {
+		t.Fatalf("expected Stop to wait for running work, got err=%v finished=%v", err, finished)
+	}
+
+	// work that outlives the shutdown window is left to the canceled base context
+	bot = &MinimalSlackBot{stop: make(chan struct{})}
+	release := make(chan struct{})
+	defer close(release)
+	bot.running.Go(func() { <-release })
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if err := bot.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
 	}
 }
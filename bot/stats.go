@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Action IDs of the leaderboard controls. The leaderboard state travels in the
+// block ID of the controls block (statsBlockPrefix + JSON).
+const (
+	statsPeriodAction = "stats_period"
+	statsFromAction   = "stats_from"
+	statsToAction     = "stats_to"
+	statsPrevAction   = "stats_prev"
+	statsNextAction   = "stats_next"
+	statsShareAction  = "stats_share"
+	statsBlockPrefix  = "beer_stats:"
+
+	defaultStatsPageSize = 5
+	maxStatsPageSize     = 15 // two lists of rows must fit into Slack's 50 block limit
+)
+
+// statsPeriods are the selectable leaderboard periods, in menu order.
+var statsPeriods = []struct{ value, label string }{
+	{"today", "Today"},
+	{"week", "This week"},
+	{"month", "This month"},
+	{"quarter", "This quarter"},
+	{"year", "This year"},
+	{"all", "All time"},
+	{"custom", "Custom range"},
+}
+
+// statsState is what a leaderboard message shows; it round-trips through the
+// controls block ID so button clicks can re-render the next state.
+type statsState struct {
+	Period string `json:"p"`
+	From   string `json:"f,omitempty"` // custom range, YYYY-MM-DD
+	To     string `json:"t,omitempty"`
+	Page   int    `json:"n,omitempty"`
+	Size   int    `json:"s"`
+}
+
+// parseStatsArgs reads /beer-stats arguments: a period name, from=/to= dates,
+// limit= (page size) and the legacy timeframe=N (last N days).
+func parseStatsArgs(text string, today time.Time) statsState {
+	st := statsState{Period: "week", Size: defaultStatsPageSize}
+	for _, p := range strings.Fields(text) {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			if isStatsPeriod(strings.ToLower(p)) {
+				st.Period = strings.ToLower(p)
+			}
+			continue
+		}
+		switch strings.ToLower(kv[0]) {
+		case "timeframe", "days":
+			if n, err := strconv.Atoi(kv[1]); err == nil && n > 0 && n <= 365 {
+				st.Period = "custom"
+				st.From = today.AddDate(0, 0, -n).Format("2006-01-02")
+				st.To = today.Format("2006-01-02")
+			}
+		case "from", "to":
+			if _, err := time.Parse("2006-01-02", kv[1]); err == nil {
+				st.Period = "custom"
+				if strings.EqualFold(kv[0], "from") {
+					st.From = kv[1]
+				} else {
+					st.To = kv[1]
+				}
+			}
+		case "limit":
+			if n, err := strconv.Atoi(kv[1]); err == nil && n > 0 {
+				st.Size = min(n, maxStatsPageSize)
+			}
+		}
+	}
+	return st
+}
+
+func isStatsPeriod(v string) bool {
+	for _, p := range statsPeriods {
+		if p.value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// statsRange resolves the state's period to inclusive calendar days ending today.
+func (st statsState) statsRange(today time.Time) (time.Time, time.Time) {
+	y, m, d := today.Date()
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, today.Location()) }
+	end := day(y, m, d)
+	switch st.Period {
+	case "today":
+		return end, end
+	case "month":
+		return day(y, m, 1), end
+	case "quarter":
+		return day(y, m-(m-1)%3, 1), end
+	case "year":
+		return day(y, 1, 1), end
+	case "all":
+		return time.Time{}, end
+	case "custom":
+		start, to := end.AddDate(0, 0, -7), end
+		if t, err := time.ParseInLocation("2006-01-02", st.From, today.Location()); err == nil {
+			start = t
+		}
+		if t, err := time.ParseInLocation("2006-01-02", st.To, today.Location()); err == nil {
+			to = t
+		}
+		if to.Before(start) {
+			start, to = to, start
+		}
+		return start, to
+	default: // week, starting Monday
+		return end.AddDate(0, 0, -((int(end.Weekday()) + 6) % 7)), end
+	}
+}
+
+// describe renders the period for the leaderboard subtitle.
+func (st statsState) describe(start, end time.Time) string {
+	label := "This week"
+	for _, p := range statsPeriods {
+		if p.value == st.Period {
+			label = p.label
+		}
+	}
+	switch {
+	case st.Period == "all":
+		return label
+	case start.Equal(end):
+		return fmt.Sprintf("%s · %s", label, end.Format("Jan 2, 2006"))
+	default:
+		return fmt.Sprintf("%s · %s – %s", label, start.Format("Jan 2"), end.Format("Jan 2, 2006"))
+	}
+}
+
+// leaderRow is one leaderboard entry with its tie-aware rank.
+type leaderRow struct {
+	user  string
+	total int
+	rank  int
+}
+
+// rankRows assigns competition ranks: equal totals share a rank and the next
+// distinct total skips ahead (1, 1, 3).
+func rankRows(rows [][2]string) []leaderRow {
+	out := make([]leaderRow, 0, len(rows))
+	for i, r := range rows {
+		total, _ := strconv.Atoi(r[1])
+		rank := i + 1
+		if i > 0 && total == out[i-1].total {
+			rank = out[i-1].rank
+		}
+		out = append(out, leaderRow{user: r[0], total: total, rank: rank})
+	}
+	return out
+}
+
+// leaderboard is one rendered page.
+type leaderboard struct {
+	state             statsState
+	subtitle          string
+	givers, receivers []leaderRow
+	hasNext           bool
+	profiles          map[string]slack.User
+}
+
+// buildLeaderboard loads one page of the leaderboard for the user's time zone.
+func (bot *MinimalSlackBot) buildLeaderboard(ctx context.Context, user string, st statsState) (*leaderboard, error) {
+	if st.Size < 1 || st.Size > maxStatsPageSize {
+		st.Size = defaultStatsPageSize
+	}
+	st.Page = max(st.Page, 0)
+	start, end := st.statsRange(time.Now().In(bot.userLocation(user)))
+
+	// Ranks depend on every row above the page, so fetch from the top (+1 to detect a next page)
+	limit := (st.Page+1)*st.Size + 1
+	givers, err := bot.store.TopGivers(ctx, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	receivers, err := bot.store.TopReceivers(ctx, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	lb := &leaderboard{state: st, subtitle: st.describe(start, end)}
+	page := func(rows []leaderRow) []leaderRow {
+		from, to := st.Page*st.Size, (st.Page+1)*st.Size
+		if len(rows) > to {
+			lb.hasNext = true
+		}
+		if from >= len(rows) {
+			return nil
+		}
+		return rows[from:min(to, len(rows))]
+	}
+	lb.givers = page(rankRows(givers))
+	lb.receivers = page(rankRows(receivers))
+
+	var ids []string
+	for _, r := range append(append([]leaderRow(nil), lb.givers...), lb.receivers...) {
+		ids = append(ids, r.user)
+	}
+	lb.profiles = bot.userProfiles(ids)
+	return lb, nil
+}
+
+// userProfiles looks up names and avatars; users that cannot be resolved are
+// rendered as plain mentions.
+func (bot *MinimalSlackBot) userProfiles(ids []string) map[string]slack.User {
+	out := map[string]slack.User{}
+	if bot.api == nil || len(ids) == 0 {
+		return out
+	}
+	users, err := bot.api.GetUsersInfo(ids...)
+	if err != nil || users == nil {
+		bot.logger.Debug().Err(err).Msg("Failed to look up leaderboard profiles")
+		return out
+	}
+	for _, u := range *users {
+		out[u.ID] = u
+	}
+	return out
+}
+
+// blocks renders the leaderboard. Interactive leaderboards (the ephemeral one)
+// carry the period selector, paging and share controls.
+func (lb *leaderboard) blocks(interactive bool, sharedBy string) []slack.Block {
+	plain := func(t string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.PlainTextType, t, false, false)
+	}
+	md := func(t string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, t, false, false)
+	}
+
+	context := lb.subtitle
+	if sharedBy != "" {
+		context += fmt.Sprintf(" · shared by <@%s>", sharedBy)
+	}
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(plain("🍺 Beer leaderboard")),
+		slack.NewContextBlock("", md(context)),
+	}
+	blocks = append(blocks, lb.rankingBlocks()...)
+	if !interactive {
+		return blocks
+	}
+
+	var options []*slack.OptionBlockObject
+	var selected *slack.OptionBlockObject
+	for _, p := range statsPeriods {
+		o := slack.NewOptionBlockObject(p.value, plain(p.label), nil)
+		if p.value == lb.state.Period {
+			selected = o
+		}
+		options = append(options, o)
+	}
+	period := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plain("Period"), statsPeriodAction, options...)
+	period.InitialOption = selected
+	controls := []slack.BlockElement{period}
+	if lb.state.Period == "custom" {
+		from := slack.NewDatePickerBlockElement(statsFromAction)
+		from.InitialDate = lb.state.From
+		from.Placeholder = plain("From")
+		to := slack.NewDatePickerBlockElement(statsToAction)
+		to.InitialDate = lb.state.To
+		to.Placeholder = plain("To")
+		controls = append(controls, from, to)
+	}
+	if lb.state.Page > 0 {
+		controls = append(controls, slack.NewButtonBlockElement(statsPrevAction, "prev", plain("◀ Previous")))
+	}
+	if lb.hasNext {
+		controls = append(controls, slack.NewButtonBlockElement(statsNextAction, "next", plain("Next ▶")))
+	}
+	controls = append(controls, slack.NewButtonBlockElement(statsShareAction, "share", plain("Post to channel")))
+	state, _ := json.Marshal(lb.state)
+	blocks = append(blocks, slack.NewActionBlock(statsBlockPrefix+string(state), controls...))
+	return blocks
+}
+
+// rankingBlocks renders the ranked givers and receivers with names and avatars.
+func (lb *leaderboard) rankingBlocks() []slack.Block {
+	md := func(t string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, t, false, false)
+	}
+	var blocks []slack.Block
+	list := func(title string, rows []leaderRow) {
+		blocks = append(blocks, slack.NewSectionBlock(md("*"+title+"*"), nil, nil))
+		if len(rows) == 0 {
+			blocks = append(blocks, slack.NewContextBlock("", md("(none)")))
+			return
+		}
+		for _, r := range rows {
+			name := fmt.Sprintf("<@%s>", r.user)
+			var elements []slack.MixedElement
+			if u, ok := lb.profiles[r.user]; ok {
+				if n := u.Profile.DisplayName; n != "" {
+					name = n
+				} else if u.RealName != "" {
+					name = u.RealName
+				}
+				if u.Profile.Image48 != "" {
+					elements = append(elements, slack.NewImageBlockElement(u.Profile.Image48, name))
+				}
+			}
+			elements = append(elements, md(fmt.Sprintf("*%d.* %s — %s", r.rank, name, beerCount(r.total))))
+			blocks = append(blocks, slack.NewContextBlock("", elements...))
+		}
+	}
+	list("Top givers", lb.givers)
+	list("Top receivers", lb.receivers)
+	return blocks
+}
+
+// fallbackText is the notification text of a leaderboard message.
+func (lb *leaderboard) fallbackText() string {
+	return "Beer leaderboard — " + lb.subtitle
+}
+
+// handleBeerStats implements /beer-stats: an ephemeral, interactive leaderboard.
+func (bot *MinimalSlackBot) handleBeerStats(ctx context.Context, cmd slack.SlashCommand) {
+	st := parseStatsArgs(cmd.Text, time.Now().In(bot.userLocation(cmd.UserID)))
+	lb, err := bot.buildLeaderboard(ctx, cmd.UserID, st)
+	if err != nil {
+		bot.logger.Error().Err(err).Msg("Failed to build leaderboard")
+		bot.postEphemeral(cmd.ChannelID, cmd.UserID, "Error generating stats.")
+		return
+	}
+	_, err = bot.api.PostEphemeral(cmd.ChannelID, cmd.UserID,
+		slack.MsgOptionText(lb.fallbackText(), false),
+		slack.MsgOptionBlocks(lb.blocks(true, "")...),
+	)
+	if err != nil {
+		bot.logger.Debug().Err(err).Msg("Failed to post ephemeral message")
+	}
+}
+
+// handleStatsAction re-renders the ephemeral leaderboard after a control was used,
+// replacing it through the response URL, or posts it publicly to the channel.
+func (bot *MinimalSlackBot) handleStatsAction(ctx context.Context, cb slack.InteractionCallback, action *slack.BlockAction) {
+	var st statsState
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(action.BlockID, statsBlockPrefix)), &st); err != nil {
+		bot.logger.Debug().Err(err).Str("block_id", action.BlockID).Msg("Invalid leaderboard state")
+		return
+	}
+	switch action.ActionID {
+	case statsPeriodAction:
+		st.Period, st.Page = action.SelectedOption.Value, 0
+	case statsFromAction:
+		st.From, st.Page = action.SelectedDate, 0
+	case statsToAction:
+		st.To, st.Page = action.SelectedDate, 0
+	case statsPrevAction:
+		st.Page--
+	case statsNextAction:
+		st.Page++
+	}
+
+	lb, err := bot.buildLeaderboard(ctx, cb.User.ID, st)
+	if err != nil {
+		bot.logger.Error().Err(err).Msg("Failed to build leaderboard")
+		bot.postEphemeral(cb.Channel.ID, cb.User.ID, "Error generating stats.")
+		return
+	}
+
+	if action.ActionID == statsShareAction {
+		_, _, err := bot.api.PostMessage(cb.Channel.ID,
+			slack.MsgOptionText(lb.fallbackText(), false),
+			slack.MsgOptionBlocks(lb.blocks(false, cb.User.ID)...),
+		)
+		if err != nil {
+			bot.logger.Error().Err(err).Str("channel", cb.Channel.ID).Msg("Failed to share leaderboard")
+			bot.errorCounter.WithLabelValues("message_error").Inc()
+			bot.postEphemeral(cb.Channel.ID, cb.User.ID, "⚠️ Could not post the leaderboard here — is the bot a member of this channel?")
+			return
+		}
+		if cb.ResponseURL != "" {
+			_ = slack.PostWebhook(cb.ResponseURL, &slack.WebhookMessage{DeleteOriginal: true})
+		}
+		return
+	}
+
+	if cb.ResponseURL == "" {
+		return
+	}
+	err = slack.PostWebhook(cb.ResponseURL, &slack.WebhookMessage{
+		Text:            lb.fallbackText(),
+		Blocks:          &slack.Blocks{BlockSet: lb.blocks(true, "")},
+		ReplaceOriginal: true,
+	})
+	if err != nil {
+		bot.logger.Warn().Err(err).Msg("Failed to update leaderboard")
+	}
+}
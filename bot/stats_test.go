@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+)
+
+func TestStatsRange(t *testing.T) {
+	today := time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC) // a Wednesday
+	cases := []struct {
+		state      statsState
+		start, end string
+	}{
+		{statsState{Period: "today"}, "2024-05-15", "2024-05-15"},
+		{statsState{Period: "week"}, "2024-05-13", "2024-05-15"},
+		{statsState{Period: "month"}, "2024-05-01", "2024-05-15"},
+		{statsState{Period: "quarter"}, "2024-04-01", "2024-05-15"},
+		{statsState{Period: "year"}, "2024-01-01", "2024-05-15"},
+		{statsState{Period: "all"}, "0001-01-01", "2024-05-15"},
+		{statsState{Period: "custom", From: "2024-03-10", To: "2024-03-01"}, "2024-03-01", "2024-03-10"},
+	}
+	for _, c := range cases {
+		start, end := c.state.statsRange(today)
+		if got := start.Format("2006-01-02") + " " + end.Format("2006-01-02"); got != c.start+" "+c.end {
+			t.Errorf("%s: expected %s %s, got %s", c.state.Period, c.start, c.end, got)
+		}
+	}
+
+	// the legacy timeframe=N argument is a custom range of the last N days
+	st := parseStatsArgs("timeframe=7 limit=50", today)
+	if st.Period != "custom" || st.From != "2024-05-08" || st.To != "2024-05-15" || st.Size != maxStatsPageSize {
+		t.Fatalf("unexpected state %+v", st)
+	}
+	if st := parseStatsArgs("Month", today); st.Period != "month" || st.Size != defaultStatsPageSize {
+		t.Fatalf("unexpected state %+v", st)
+	}
+}
+
+func TestRankRows(t *testing.T) {
+	rows := rankRows([][2]string{{"UA", "5"}, {"UB", "5"}, {"UC", "3"}, {"UD", "1"}, {"UE", "1"}})
+	var ranks []int
+	for _, r := range rows {
+		ranks = append(ranks, r.rank)
+	}
+	if got, _ := json.Marshal(ranks); string(got) != "[1,1,3,4,4]" {
+		t.Fatalf("unexpected ranks %s", got)
+	}
+}
+
+func TestStatsPaging(t *testing.T) {
+	ctx := t.Context()
+	var replaced string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		replaced = string(body)
+	}))
+	defer hook.Close()
+
+	ms := storage.NewMemoryStore()
+	// givers this week: UA 9, UB 7, UC 7, UD 2
+	now := time.Now()
+	_ = ms.AddBeer(ctx, "UA", "UB", "1.1", now, 9, storage.BeerNote{})
+	_ = ms.AddBeer(ctx, "UB", "UC", "1.2", now, 7, storage.BeerNote{})
+	_ = ms.AddBeer(ctx, "UC", "UA", "1.3", now, 7, storage.BeerNote{})
+	_ = ms.AddBeer(ctx, "UD", "UE", "1.4", now, 2, storage.BeerNote{})
+	bot := &MinimalSlackBot{api: newTestSlackAPI(t), store: ms}
+
+	lb, err := bot.buildLeaderboard(ctx, "UX", statsState{Period: "week", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lb.hasNext || len(lb.givers) != 2 || lb.givers[1].user != "UB" {
+		t.Fatalf("unexpected first page %+v", lb)
+	}
+	blocks := lb.blocks(true, "")
+	controls := blocks[len(blocks)-1].(*slack.ActionBlock)
+
+	var cb slack.InteractionCallback
+	cb.Type = slack.InteractionTypeBlockActions
+	cb.User.ID = "UX"
+	cb.Channel.ID = "C1"
+	cb.ResponseURL = hook.URL
+	cb.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: statsNextAction, BlockID: controls.BlockID}}
+	resp, followUp := bot.handleInteraction(ctx, cb)
+	if resp != nil || followUp == nil {
+		t.Fatalf("expected plain ACK with follow-up, got %#v", resp)
+	}
+	followUp()
+
+	// the second page keeps the tie-aware rank of UC and replaces the ephemeral message
+	for _, want := range []string{`"replace_original":true`, `*2.* \u003c@UC\u003e`, `*4.* \u003c@UD\u003e`, `\"n\":1`, statsPrevAction} {
+		if !strings.Contains(replaced, want) {
+			t.Fatalf("expected %s in update, got %s", want, replaced)
+		}
+	}
+	if strings.Contains(replaced, statsNextAction) {
+		t.Fatalf("last page must not offer a next button: %s", replaced)
+	}
+}
@@ -0,0 +1,93 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/DanielWeeber/beer-with-me/bot/storage/storetest"
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "beerbot.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteStore_Contract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		s, err := storage.NewSQLiteStore(t.Context(), openDB(t))
+		if err != nil {
+			t.Fatalf("new store: %v", err)
+		}
+		return s
+	})
+}
+
+func TestMemoryStore_Contract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store { return storage.NewMemoryStore() })
+}
+
+// TestPostgresStore_Contract runs against the server in BEERBOT_TEST_POSTGRES_DSN,
+// or against a fresh in-process stand-in per test when unset.
+func TestPostgresStore_Contract(t *testing.T) {
+	dsn := os.Getenv("BEERBOT_TEST_POSTGRES_DSN")
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		dsn := dsn
+		if dsn == "" {
+			dsn = startFakePostgres(t)
+		}
+		s, err := storage.NewPostgresStore(t.Context(), openPostgresSchema(t, dsn))
+		if err != nil {
+			t.Fatalf("new store: %v", err)
+		}
+		return s
+	})
+}
+
+// openPostgresSchema creates a schema of its own for the test and connects with
+// it as search_path, so existing tables are never touched. The schema is
+// dropped when the test ends.
+func openPostgresSchema(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	admin, err := sql.Open(storage.PostgresDriver, dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("beerbot_test_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(t.Context(), `CREATE SCHEMA `+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.ExecContext(context.Background(), `DROP SCHEMA `+schema+` CASCADE`); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sql.Open(storage.PostgresDriver, dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,463 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// fakePostgres is a PostgreSQL stand-in for the store tests. It speaks the wire
+// protocol to the real pgx driver and runs the statements on a SQLite database,
+// translating the few parts of PostgreSQL's dialect that PostgresStore and its
+// migrations use. It only implements the simple query protocol, so clients
+// must connect with default_query_exec_mode=simple_protocol. Schemas can be
+// created and dropped but are not separate: each stand-in has one database.
+type fakePostgres struct {
+	db *sql.DB
+
+	mu    sync.Mutex
+	freed *sync.Cond
+	locks map[int64]*fakeSession // advisory locks and their holders
+}
+
+type fakeSession struct {
+	conn  *sql.Conn
+	locks map[int64]int // advisory locks held, with their nesting depth
+	inTx  bool
+}
+
+// startFakePostgres serves a new, empty database and returns its DSN.
+func startFakePostgres(t *testing.T) string {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "postgres.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("open fake postgres db: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakePostgres{db: db, locks: map[int64]*fakeSession{}}
+	s.freed = sync.NewCond(&s.mu)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Go(func() { s.serve(c) })
+		}
+	})
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+		db.Close()
+	})
+	return fmt.Sprintf("postgres://beerbot@%s/beerbot?sslmode=disable&default_query_exec_mode=simple_protocol", ln.Addr())
+}
+
+func (s *fakePostgres) serve(c net.Conn) {
+	defer c.Close()
+	be := pgproto3.NewBackend(c, c)
+	if !s.startup(c, be) {
+		return
+	}
+	conn, err := s.db.Conn(context.Background())
+	if err != nil {
+		return
+	}
+	sess := &fakeSession{conn: conn, locks: map[int64]int{}}
+	defer func() {
+		if sess.inTx {
+			_, _ = conn.ExecContext(context.Background(), `ROLLBACK`)
+		}
+		s.unlockAll(sess)
+		conn.Close()
+	}()
+	for {
+		msg, err := be.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			s.query(be, sess, msg.String)
+		case *pgproto3.Terminate:
+			return
+		default:
+			be.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "0A000", Message: fmt.Sprintf("fake postgres: %T not supported, use the simple protocol", msg)})
+			_ = be.Flush()
+			return
+		}
+		status := byte('I')
+		if sess.inTx {
+			status = 'T'
+		}
+		be.Send(&pgproto3.ReadyForQuery{TxStatus: status})
+		if err := be.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// startup answers the connection handshake. Cancel requests end the connection,
+// which is how pgx gives up on a canceled query anyway.
+func (s *fakePostgres) startup(c net.Conn, be *pgproto3.Backend) bool {
+	for {
+		msg, err := be.ReceiveStartupMessage()
+		if err != nil {
+			return false
+		}
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := c.Write([]byte{'N'}); err != nil {
+				return false
+			}
+		case *pgproto3.StartupMessage:
+			be.Send(&pgproto3.AuthenticationOk{})
+			for name, value := range map[string]string{
+				"server_version":              "16.0",
+				"server_encoding":             "UTF8",
+				"client_encoding":             "UTF8",
+				"standard_conforming_strings": "on",
+				"DateStyle":                   "ISO, MDY",
+				"TimeZone":                    "UTC",
+				"integer_datetimes":           "on",
+			} {
+				be.Send(&pgproto3.ParameterStatus{Name: name, Value: value})
+			}
+			be.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+			be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			return be.Flush() == nil
+		default:
+			return false
+		}
+	}
+}
+
+var (
+	advisoryLockRE = regexp.MustCompile(`(?i)^\s*SELECT\s+pg_advisory_(un)?lock\(\s*'?(-?\d+)'?\s*\)\s*;?\s*$`)
+	beginRE        = regexp.MustCompile(`(?i)^\s*(BEGIN|START TRANSACTION)\b`)
+	endTxRE        = regexp.MustCompile(`(?i)^\s*(COMMIT|END|ROLLBACK|ABORT)\b`)
+	rowsRE         = regexp.MustCompile(`(?i)^\s*(SELECT|WITH)\b|\bRETURNING\b`)
+	schemaRE       = regexp.MustCompile(`(?i)^\s*(CREATE|DROP) SCHEMA\b`)
+)
+
+// query runs one simple-protocol query and sends its results.
+func (s *fakePostgres) query(be *pgproto3.Backend, sess *fakeSession, q string) {
+	ctx := context.Background()
+	if strings.TrimSpace(stripComments(q)) == "" {
+		be.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+	if m := advisoryLockRE.FindStringSubmatch(q); m != nil {
+		key, _ := strconv.ParseInt(m[2], 10, 64)
+		name := "pg_advisory_lock"
+		if m[1] != "" {
+			name = "pg_advisory_unlock"
+			s.unlock(sess, key)
+		} else {
+			s.lock(sess, key)
+		}
+		be.Send(&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{fakeField(name, fakeVoidOID)}})
+		be.Send(&pgproto3.DataRow{Values: [][]byte{{}}})
+		be.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")})
+		return
+	}
+	switch {
+	case schemaRE.MatchString(q):
+		be.Send(&pgproto3.CommandComplete{CommandTag: []byte(commandTag(q, 0))})
+		return
+	case beginRE.MatchString(q):
+		// IMMEDIATE takes SQLite's write lock up front, so concurrent
+		// transactions wait for each other instead of failing to upgrade
+		if _, err := sess.conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+			sendError(be, err)
+			return
+		}
+		sess.inTx = true
+		be.Send(&pgproto3.CommandComplete{CommandTag: []byte("BEGIN")})
+		return
+	case endTxRE.MatchString(q):
+		stmt := strings.ToUpper(strings.Fields(q)[0])
+		if stmt == "END" {
+			stmt = "COMMIT"
+		} else if stmt == "ABORT" {
+			stmt = "ROLLBACK"
+		}
+		if sess.inTx {
+			if _, err := sess.conn.ExecContext(ctx, stmt); err != nil {
+				sendError(be, err)
+				return
+			}
+		}
+		sess.inTx = false
+		be.Send(&pgproto3.CommandComplete{CommandTag: []byte(stmt)})
+		return
+	}
+
+	stmt := translatePostgres(q)
+	if !rowsRE.MatchString(stmt) {
+		res, err := sess.conn.ExecContext(ctx, stmt)
+		if err != nil {
+			sendError(be, err)
+			return
+		}
+		n, _ := res.RowsAffected()
+		be.Send(&pgproto3.CommandComplete{CommandTag: []byte(commandTag(stmt, n))})
+		return
+	}
+	rows, err := sess.conn.QueryContext(ctx, stmt)
+	if err != nil {
+		sendError(be, err)
+		return
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		sendError(be, err)
+		return
+	}
+	var data [][]any
+	for rows.Next() {
+		row := make([]any, len(types))
+		ptrs := make([]any, len(types))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			sendError(be, err)
+			return
+		}
+		data = append(data, row)
+	}
+	if err := rows.Err(); err != nil {
+		sendError(be, err)
+		return
+	}
+	oids := make([]uint32, len(types))
+	fields := make([]pgproto3.FieldDescription, len(types))
+	for i, ct := range types {
+		oids[i] = columnOID(ct.DatabaseTypeName(), data, i)
+		fields[i] = fakeField(ct.Name(), oids[i])
+	}
+	be.Send(&pgproto3.RowDescription{Fields: fields})
+	for _, row := range data {
+		values := make([][]byte, len(row))
+		for i, v := range row {
+			values[i] = encodeText(v, oids[i])
+		}
+		be.Send(&pgproto3.DataRow{Values: values})
+	}
+	be.Send(&pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("SELECT %d", len(data)))})
+}
+
+func (s *fakePostgres) lock(sess *fakeSession, key int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.locks[key] != nil && s.locks[key] != sess {
+		s.freed.Wait()
+	}
+	s.locks[key] = sess
+	sess.locks[key]++
+}
+
+func (s *fakePostgres) unlock(sess *fakeSession, key int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[key] != sess {
+		return
+	}
+	if sess.locks[key]--; sess.locks[key] == 0 {
+		delete(sess.locks, key)
+		delete(s.locks, key)
+		s.freed.Broadcast()
+	}
+}
+
+// unlockAll releases the locks of a closed session, as PostgreSQL does.
+func (s *fakePostgres) unlockAll(sess *fakeSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range sess.locks {
+		delete(s.locks, key)
+	}
+	s.freed.Broadcast()
+}
+
+func sendError(be *pgproto3.Backend, err error) {
+	code := "XX000"
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		code = "23505"
+	}
+	be.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: code, Message: err.Error()})
+}
+
+func commandTag(stmt string, n int64) string {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	switch fields[0] {
+	case "INSERT":
+		return fmt.Sprintf("INSERT 0 %d", n)
+	case "UPDATE", "DELETE":
+		return fmt.Sprintf("%s %d", fields[0], n)
+	}
+	if len(fields) > 1 {
+		return fields[0] + " " + fields[1]
+	}
+	return fields[0]
+}
+
+// PostgreSQL type OIDs of the columns the fake returns.
+const (
+	fakeBoolOID        = 16
+	fakeByteaOID       = 17
+	fakeInt8OID        = 20
+	fakeTextOID        = 25
+	fakeFloat8OID      = 701
+	fakeDateOID        = 1082
+	fakeTimestamptzOID = 1184
+	fakeVoidOID        = 2278
+)
+
+func fakeField(name string, oid uint32) pgproto3.FieldDescription {
+	return pgproto3.FieldDescription{Name: []byte(name), DataTypeOID: oid, DataTypeSize: -1, TypeModifier: -1}
+}
+
+// columnOID maps a column's declared type to its PostgreSQL type. Expressions
+// have no declared type and are typed by their values.
+func columnOID(decl string, data [][]any, col int) uint32 {
+	decl = strings.ToUpper(decl)
+	switch {
+	case decl == "TIMESTAMPTZ":
+		return fakeTimestamptzOID
+	case decl == "DATE":
+		return fakeDateOID
+	case strings.Contains(decl, "INT") || strings.Contains(decl, "SERIAL"):
+		return fakeInt8OID
+	case decl == "BOOLEAN":
+		return fakeBoolOID
+	case decl != "":
+		return fakeTextOID
+	}
+	for _, row := range data {
+		switch row[col].(type) {
+		case int64:
+			return fakeInt8OID
+		case float64:
+			return fakeFloat8OID
+		case []byte:
+			return fakeByteaOID
+		case time.Time:
+			return fakeTimestamptzOID
+		case string:
+			return fakeTextOID
+		}
+	}
+	return fakeTextOID
+}
+
+// encodeText renders a value in PostgreSQL's text format; nil is NULL.
+func encodeText(v any, oid uint32) []byte {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case int64:
+		if oid == fakeBoolOID {
+			return []byte(map[bool]string{true: "t", false: "f"}[v != 0])
+		}
+		return []byte(strconv.FormatInt(v, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case []byte:
+		if oid == fakeByteaOID {
+			return []byte(fmt.Sprintf(`\x%x`, v))
+		}
+		return v
+	case time.Time:
+		if oid == fakeDateOID {
+			return []byte(v.Format(time.DateOnly))
+		}
+		return []byte(formatTimestamptz(v))
+	case string:
+		if oid == fakeTimestamptzOID {
+			if t, ok := parseTimestamptz(v); ok {
+				return []byte(formatTimestamptz(t))
+			}
+		}
+		return []byte(v)
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// Timestamps are stored in one fixed-width UTC format, so SQLite compares and
+// orders them as text the way PostgreSQL does as timestamptz.
+func formatTimestamptz(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000000") + "+00"
+}
+
+var timestamptzLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func parseTimestamptz(s string) (time.Time, bool) {
+	for _, layout := range timestamptzLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	stringLiteralRE = regexp.MustCompile(`'(?:[^']|'')*'`)
+	timestampRE     = regexp.MustCompile(`^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d`)
+	limitRE         = regexp.MustCompile(`(?i)\bLIMIT\s+(?:'(-?\d+)'|(NULL))`)
+	textCastRE      = regexp.MustCompile(`([\w.]+)::text\b`)
+	commentRE       = regexp.MustCompile(`--[^\n]*`)
+)
+
+// translatePostgres rewrites a statement, with its arguments already inlined by
+// pgx, into SQLite.
+func translatePostgres(q string) string {
+	q = strings.NewReplacer(
+		"BIGSERIAL PRIMARY KEY", "INTEGER PRIMARY KEY AUTOINCREMENT",
+		"DEFAULT now()", "DEFAULT (strftime('%Y-%m-%d %H:%M:%f000+00', 'now'))",
+		"now()", "strftime('%Y-%m-%d %H:%M:%f000+00', 'now')",
+	).Replace(q)
+	q = textCastRE.ReplaceAllString(q, "CAST($1 AS TEXT)")
+	q = limitRE.ReplaceAllStringFunc(q, func(m string) string {
+		sub := limitRE.FindStringSubmatch(m)
+		if sub[2] != "" {
+			return "LIMIT -1"
+		}
+		return "LIMIT " + sub[1]
+	})
+	return stringLiteralRE.ReplaceAllStringFunc(q, func(lit string) string {
+		s := strings.ReplaceAll(lit[1:len(lit)-1], "''", "'")
+		if !timestampRE.MatchString(s) {
+			return lit
+		}
+		if t, ok := parseTimestamptz(s); ok {
+			return "'" + formatTimestamptz(t) + "'"
+		}
+		return lit
+	})
+}
+
+func stripComments(q string) string {
+	return commentRE.ReplaceAllString(q, "")
+}
@@ -0,0 +1,608 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store in memory with the same semantics as SQLiteStore.
+// It backs tests and DB_DRIVER=memory demo runs; everything is lost on exit. It is
+// safe for concurrent use.
+type MemoryStore struct {
+	mu         sync.Mutex
+	nextID     int
+	events     map[string]bool
+	beers      []*memoryBeer
+	outcomes   []Outcome
+	rules      map[ChannelRule]bool
+	settings   map[string]string
+	admin      []AdminAction
+	prefs      map[string]Preferences
+	digestRuns map[string]string // name + slot -> status
+}
+
+type memoryBeer struct {
+	id               int
+	giver, recipient string
+	ts               string
+	time             time.Time
+	day              string
+	count            int
+	note             BeerNote
+	revoked          bool
+	confirmChannel   string
+	confirmTS        string
+}
+
+// Outcome is the recorded result of a gift attempt (see RecordBeerEventOutcome).
+type Outcome struct {
+	EventID   string
+	Giver     string
+	Recipient string
+	Quantity  int
+	Status    string
+	Time      time.Time
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		events:     map[string]bool{},
+		rules:      map[ChannelRule]bool{},
+		settings:   map[string]string{},
+		prefs:      map[string]Preferences{},
+		digestRuns: map[string]string{},
+	}
+}
+
+// Outcomes returns the recorded results, oldest first.
+func (s *MemoryStore) Outcomes() []Outcome {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Outcome(nil), s.outcomes...)
+}
+
+// lock takes the mutex unless ctx is already done, so a canceled caller fails
+// the same way it would against a database.
+func (s *MemoryStore) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	return nil
+}
+
+// stamp truncates to whole seconds in UTC, the precision SQLiteStore keeps.
+func stamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// live returns the gifts that were not undone, in insertion order.
+func (s *MemoryStore) live(keep func(b *memoryBeer) bool) []*memoryBeer {
+	var out []*memoryBeer
+	for _, b := range s.beers {
+		if !b.revoked && keep(b) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+func inDays(b *memoryBeer, start, end time.Time) bool {
+	return b.day >= start.Format("2006-01-02") && b.day <= end.Format("2006-01-02")
+}
+
+// limited applies a SQL-style limit: negative means no limit.
+func limited[T any](rows []T, limit int) []T {
+	if limit >= 0 && limit < len(rows) {
+		return rows[:limit]
+	}
+	return rows
+}
+
+func (s *MemoryStore) TryMarkEventProcessed(ctx context.Context, eventID string, t time.Time) (bool, error) {
+	if err := s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	if s.events[eventID] {
+		return false, nil
+	}
+	s.events[eventID] = true
+	return true, nil
+}
+
+func (s *MemoryStore) UnmarkEventProcessed(ctx context.Context, eventID string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	delete(s.events, eventID)
+	return nil
+}
+
+func (s *MemoryStore) AddBeer(ctx context.Context, giver, recipient, ts string, eventTime time.Time, count int, note BeerNote) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	for _, b := range s.beers {
+		if b.giver == giver && b.recipient == recipient && b.ts == ts {
+			b.count, b.note, b.revoked = count, note, false
+			return nil
+		}
+	}
+	s.nextID++
+	s.beers = append(s.beers, &memoryBeer{
+		id: s.nextID, giver: giver, recipient: recipient, ts: ts,
+		time: stamp(eventTime), day: eventTime.Format("2006-01-02"), count: count, note: note,
+	})
+	return nil
+}
+
+func (s *MemoryStore) RevokeBeer(ctx context.Context, giver, recipient, ts string, at time.Time) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	for _, b := range s.beers {
+		if b.giver == giver && b.recipient == recipient && b.ts == ts {
+			b.revoked = true
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetConfirmation(ctx context.Context, giver, ts, channel, confirmTS string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	for _, b := range s.beers {
+		if b.giver == giver && b.ts == ts {
+			b.confirmChannel, b.confirmTS = channel, confirmTS
+		}
+	}
+	return nil
+}
+
+// newestFirst orders gifts like the SQL stores: by time, then insertion.
+func newestFirst(rows []*memoryBeer) []*memoryBeer {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].time.Equal(rows[j].time) {
+			return rows[i].time.After(rows[j].time)
+		}
+		return rows[i].id > rows[j].id
+	})
+	return rows
+}
+
+func (s *MemoryStore) LatestGift(ctx context.Context, giver string) (*GiftRef, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	rows := newestFirst(s.live(func(b *memoryBeer) bool { return b.giver == giver }))
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	b := rows[0]
+	return &GiftRef{TS: b.ts, Time: b.time, ConfirmChannel: b.confirmChannel, ConfirmTS: b.confirmTS}, nil
+}
+
+func (s *MemoryStore) GiftAt(ctx context.Context, giver, ts string) (*GiftRef, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	for _, b := range s.live(func(b *memoryBeer) bool { return b.giver == giver && b.ts == ts }) {
+		return &GiftRef{TS: b.ts, Time: b.time, ConfirmChannel: b.confirmChannel, ConfirmTS: b.confirmTS}, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) GetBeersByTS(ctx context.Context, giver, ts string) (map[string]int, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	out := map[string]int{}
+	for _, b := range s.live(func(b *memoryBeer) bool { return b.giver == giver && b.ts == ts }) {
+		out[b.recipient] = b.count
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) RecordBeerEventOutcome(ctx context.Context, eventID, giverID, recipientID string, quantity int, status string, t time.Time) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	o := Outcome{EventID: eventID, Giver: giverID, Recipient: recipientID, Quantity: quantity, Status: status, Time: stamp(t)}
+	for i, prev := range s.outcomes {
+		if prev.EventID == eventID && prev.Recipient == recipientID {
+			if status != "duplicate" {
+				s.outcomes[i] = o
+			}
+			return nil
+		}
+	}
+	s.outcomes = append(s.outcomes, o)
+	return nil
+}
+
+func records(rows []*memoryBeer) []BeerRecord {
+	var out []BeerRecord
+	for _, b := range rows {
+		out = append(out, BeerRecord{
+			Giver: b.giver, Recipient: b.recipient, Count: b.count, TS: b.ts, Time: b.time, Day: b.day,
+			Reason: b.note.Reason, ChannelID: b.note.ChannelID, Permalink: b.note.Permalink,
+		})
+	}
+	return out
+}
+
+func (s *MemoryStore) RecentBeers(ctx context.Context, user string, limit int) ([]BeerRecord, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	rows := s.live(func(b *memoryBeer) bool { return user == "" || b.giver == user || b.recipient == user })
+	return records(limited(newestFirst(rows), limit)), nil
+}
+
+func (s *MemoryStore) RecentReceived(ctx context.Context, recipient string, limit int) ([]BeerRecord, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	rows := s.live(func(b *memoryBeer) bool { return b.recipient == recipient })
+	return records(limited(newestFirst(rows), limit)), nil
+}
+
+func (s *MemoryStore) ReceivedSince(ctx context.Context, recipient string, since time.Time) ([]BeerRecord, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	rows := newestFirst(s.live(func(b *memoryBeer) bool { return b.recipient == recipient && b.time.After(stamp(since)) }))
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	return records(rows), nil
+}
+
+func (s *MemoryStore) GivingDays(ctx context.Context, giver string, limit int) ([]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var days []string
+	for _, b := range s.live(func(b *memoryBeer) bool { return b.giver == giver }) {
+		if !seen[b.day] {
+			seen[b.day] = true
+			days = append(days, b.day)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	return limited(days, limit), nil
+}
+
+// ranking sums counts per user and orders by total, then user ID.
+func ranking(rows []*memoryBeer, user func(b *memoryBeer) string, limit int) [][2]string {
+	if limit <= 0 {
+		limit = 5
+	}
+	totals := map[string]int{}
+	for _, b := range rows {
+		totals[user(b)] += b.count
+	}
+	users := make([]string, 0, len(totals))
+	for u := range totals {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if totals[users[i]] != totals[users[j]] {
+			return totals[users[i]] > totals[users[j]]
+		}
+		return users[i] < users[j]
+	})
+	var out [][2]string
+	for _, u := range limited(users, limit) {
+		out = append(out, [2]string{u, strconv.Itoa(totals[u])})
+	}
+	return out
+}
+
+func giverOf(b *memoryBeer) string     { return b.giver }
+func recipientOf(b *memoryBeer) string { return b.recipient }
+
+func (s *MemoryStore) TopGivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	return ranking(s.live(func(b *memoryBeer) bool { return b.giver != GrantGiver && inDays(b, start, end) }), giverOf, limit), nil
+}
+
+func (s *MemoryStore) TopReceivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	return ranking(s.live(func(b *memoryBeer) bool { return inDays(b, start, end) }), recipientOf, limit), nil
+}
+
+func (s *MemoryStore) TopRecipientsOf(ctx context.Context, giver string, limit int) ([][2]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	return ranking(s.live(func(b *memoryBeer) bool { return b.giver == giver }), recipientOf, limit), nil
+}
+
+func (s *MemoryStore) TopGiversTo(ctx context.Context, recipient string, limit int) ([][2]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	return ranking(s.live(func(b *memoryBeer) bool { return b.recipient == recipient && b.giver != GrantGiver }), giverOf, limit), nil
+}
+
+// sum adds up the counts of the live gifts that match.
+func (s *MemoryStore) sum(ctx context.Context, keep func(b *memoryBeer) bool) (int, error) {
+	if err := s.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer s.mu.Unlock()
+	total := 0
+	for _, b := range s.live(keep) {
+		total += b.count
+	}
+	return total, nil
+}
+
+func (s *MemoryStore) UserTotals(ctx context.Context, user string, start, end time.Time) (int, int, error) {
+	given, err := s.sum(ctx, func(b *memoryBeer) bool { return b.giver == user && inDays(b, start, end) })
+	if err != nil {
+		return 0, 0, err
+	}
+	received, err := s.sum(ctx, func(b *memoryBeer) bool { return b.recipient == user && inDays(b, start, end) })
+	return given, received, err
+}
+
+func (s *MemoryStore) TotalBeers(ctx context.Context, start, end time.Time) (int, error) {
+	return s.sum(ctx, func(b *memoryBeer) bool { return inDays(b, start, end) })
+}
+
+func (s *MemoryStore) CountGivenInDateRange(ctx context.Context, giver string, start, end time.Time) (int, error) {
+	return s.sum(ctx, func(b *memoryBeer) bool { return b.giver == giver && inDays(b, start, end) })
+}
+
+func (s *MemoryStore) CountReceivedInDateRange(ctx context.Context, recipient string, start, end time.Time) (int, error) {
+	return s.sum(ctx, func(b *memoryBeer) bool { return b.recipient == recipient && inDays(b, start, end) })
+}
+
+func (s *MemoryStore) CountGivenOnDate(ctx context.Context, giver string, date string) (int, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+	return s.CountGivenInDateRange(ctx, giver, t, t)
+}
+
+func (s *MemoryStore) Newcomers(ctx context.Context, start, end time.Time) ([]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	first := map[string]string{}
+	for _, b := range s.live(func(*memoryBeer) bool { return true }) {
+		for _, u := range []string{b.giver, b.recipient} {
+			if u == GrantGiver {
+				continue
+			}
+			if d, ok := first[u]; !ok || b.day < d {
+				first[u] = b.day
+			}
+		}
+	}
+	from, to := start.Format("2006-01-02"), end.Format("2006-01-02")
+	var out []string
+	for u, d := range first {
+		if d >= from && d <= to {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if first[out[i]] != first[out[j]] {
+			return first[out[i]] < first[out[j]]
+		}
+		return out[i] < out[j]
+	})
+	return out, nil
+}
+
+// users returns the distinct users of the live gifts, in first-seen order.
+func (s *MemoryStore) users(ctx context.Context, user func(b *memoryBeer) string) ([]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var out []string
+	for _, b := range s.live(func(*memoryBeer) bool { return true }) {
+		if u := user(b); u != GrantGiver && !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) GetAllGivers(ctx context.Context) ([]string, error) {
+	return s.users(ctx, giverOf)
+}
+
+func (s *MemoryStore) GetAllRecipients(ctx context.Context) ([]string, error) {
+	return s.users(ctx, recipientOf)
+}
+
+func (s *MemoryStore) GetChannelRules(ctx context.Context) ([]ChannelRule, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	var out []ChannelRule
+	for r := range s.rules {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].List != out[j].List {
+			return out[i].List < out[j].List
+		}
+		return out[i].Entry < out[j].Entry
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) AddChannelRule(ctx context.Context, list, entry string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	s.rules[ChannelRule{List: list, Entry: entry}] = true
+	return nil
+}
+
+func (s *MemoryStore) RemoveChannelRule(ctx context.Context, list, entry string) (bool, error) {
+	if err := s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	r := ChannelRule{List: list, Entry: entry}
+	existed := s.rules[r]
+	delete(s.rules, r)
+	return existed, nil
+}
+
+func (s *MemoryStore) GetSettings(ctx context.Context) (map[string]string, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	out := make(map[string]string, len(s.settings))
+	for k, v := range s.settings {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SetSetting(ctx context.Context, key, value string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	s.settings[key] = value
+	return nil
+}
+
+func (s *MemoryStore) RecordAdminAction(ctx context.Context, admin, action, detail string, t time.Time) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	s.admin = append(s.admin, AdminAction{Admin: admin, Action: action, Detail: detail, Time: stamp(t)})
+	return nil
+}
+
+func (s *MemoryStore) AdminActions(ctx context.Context, limit int) ([]AdminAction, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	var out []AdminAction
+	for i := len(s.admin) - 1; i >= 0; i-- {
+		out = append(out, s.admin[i])
+	}
+	return limited(out, limit), nil
+}
+
+func (s *MemoryStore) GetPreferences(ctx context.Context, user string) (Preferences, error) {
+	if err := s.lock(ctx); err != nil {
+		return Preferences{}, err
+	}
+	defer s.mu.Unlock()
+	if p, ok := s.prefs[user]; ok {
+		return p, nil
+	}
+	return Preferences{User: user}, nil
+}
+
+func (s *MemoryStore) ListPreferences(ctx context.Context) ([]Preferences, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	var out []Preferences
+	for _, p := range s.prefs {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].User < out[j].User })
+	return out, nil
+}
+
+// updatePrefs applies fn to the user's preferences, creating them if needed.
+func (s *MemoryStore) updatePrefs(ctx context.Context, user string, fn func(p *Preferences)) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	p, ok := s.prefs[user]
+	if !ok {
+		p = Preferences{User: user}
+	}
+	fn(&p)
+	s.prefs[user] = p
+	return nil
+}
+
+func (s *MemoryStore) SetNotify(ctx context.Context, user, notify string) error {
+	return s.updatePrefs(ctx, user, func(p *Preferences) { p.Notify = notify })
+}
+
+func (s *MemoryStore) SetDigestSent(ctx context.Context, user string, t time.Time) error {
+	return s.updatePrefs(ctx, user, func(p *Preferences) { p.DigestSent = stamp(t) })
+}
+
+func digestRunKey(name string, slot time.Time) string {
+	return name + "@" + slot.UTC().Format(time.RFC3339)
+}
+
+func (s *MemoryStore) ClaimDigestRun(ctx context.Context, name string, slot time.Time) (bool, error) {
+	if err := s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	key := digestRunKey(name, slot)
+	if _, ok := s.digestRuns[key]; ok {
+		return false, nil
+	}
+	s.digestRuns[key] = "running"
+	return true, nil
+}
+
+func (s *MemoryStore) FinishDigestRun(ctx context.Context, name string, slot time.Time, status, channel, ts string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if key := digestRunKey(name, slot); s.digestRuns[key] != "" {
+		s.digestRuns[key] = status
+	}
+	return nil
+}
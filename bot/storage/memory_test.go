@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	ctx := t.Context()
+	s := NewMemoryStore()
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = s.TryMarkEventProcessed(ctx, "Ev", day)
+			_ = s.AddBeer(ctx, fmt.Sprintf("G%d", i%4), "R", fmt.Sprintf("%d.1", i), day, 1, BeerNote{})
+			_, _ = s.TopGivers(ctx, day, day, 5)
+		}(i)
+	}
+	wg.Wait()
+	if n, _ := s.TotalBeers(ctx, day, day); n != 20 {
+		t.Fatalf("expected 20 beers, got %d", n)
+	}
+	if top, _ := s.TopGivers(ctx, day, day, 5); len(top) != 4 || top[0][1] != "5" {
+		t.Fatalf("unexpected top givers %v", top)
+	}
+}
+
+func TestMemoryStore_RecordBeerEventOutcome(t *testing.T) {
+	ctx := t.Context()
+	s := NewMemoryStore()
+	now := time.Now()
+	for _, o := range []struct{ event, recipient, status string }{
+		{"E1", "R1", "success"},
+		{"E1", "R1", "duplicate"}, // redelivery: keeps the success
+		{"E1", "R2", "success"},
+		{"E2", "R1", "error"},
+		{"E2", "R1", "undone"}, // retry: replaces the error
+	} {
+		if err := s.RecordBeerEventOutcome(ctx, o.event, "G", o.recipient, 1, o.status, now); err != nil {
+			t.Fatalf("record %+v: %v", o, err)
+		}
+	}
+	var got []string
+	for _, o := range s.Outcomes() {
+		got = append(got, o.EventID+":"+o.Recipient+":"+o.Status)
+	}
+	if want := "E1:R1:success,E1:R2:success,E2:R1:undone"; strings.Join(got, ",") != want {
+		t.Fatalf("outcomes: got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,550 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migration is one numbered schema change. Each runs in its own transaction
+// together with its schema_migrations bookkeeping. Steps are written to be
+// idempotent (IF NOT EXISTS, addColumn) so databases created before versioning,
+// which have tables but no schema_migrations rows, are adopted in place.
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+	down    func(ctx context.Context, tx *sql.Tx) error
+}
+
+// sqliteMigrations is the ordered schema history. Append only: never renumber or
+// edit a released migration, add a new one instead.
+var sqliteMigrations = []migration{
+	{1, "baseline: beers, emoji counts, processed events, audit", migrateBaselineUp, dropTables("beer_events_audit", "processed_events", "emoji_counts", "beers")},
+	{2, "channel allow/deny rules", execUp(`CREATE TABLE IF NOT EXISTS channel_rules (
+			list TEXT NOT NULL, -- allow|deny
+			entry TEXT NOT NULL, -- channel ID or channel type (public|private|im|mpim)
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (list, entry)
+		);`), dropTables("channel_rules")},
+	{3, "local calendar day of gifts", migrateLocalDayUp, migrateLocalDayDown},
+	{4, "gift reason, channel, permalink, undo and confirmation", migrateGiftDetailsUp, dropColumns("beers", giftDetailColumns...)},
+	{5, "runtime settings and admin audit", execUp(
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY, -- read_only|max_gift|max_per_day
+			value TEXT NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS admin_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			admin_id TEXT NOT NULL,
+			action TEXT NOT NULL, -- readonly|limits|channels|revoke|grant
+			detail TEXT NOT NULL,
+			ts_rfc DATETIME NOT NULL
+		);`), dropTables("admin_audit", "settings")},
+	{6, "user preferences", execUp(`CREATE TABLE IF NOT EXISTS preferences (
+			user_id TEXT PRIMARY KEY,
+			notify TEXT NOT NULL DEFAULT '', -- off|instant|daily; empty uses NOTIFY_DEFAULT
+			digest_sent DATETIME, -- end of the last daily digest
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`), dropTables("preferences")},
+	{7, "scheduled digest runs", execUp(`CREATE TABLE IF NOT EXISTS digest_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL, -- which digest (one per channel)
+			slot TEXT NOT NULL, -- scheduled time, RFC3339
+			status TEXT NOT NULL, -- running|posted|failed
+			channel_id TEXT NOT NULL DEFAULT '',
+			message_ts TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(name, slot)
+		);`), dropTables("digest_runs")},
+}
+
+// Migrator applies a backend's migrations and records them in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	migrations []migration
+	postgres   bool // use $N placeholders
+}
+
+// NewSQLiteMigrator returns the migrator for a SQLite database.
+func NewSQLiteMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db, migrations: sqliteMigrations}
+}
+
+// latest is the schema version this binary writes.
+func (m *Migrator) latest() int {
+	return m.migrations[len(m.migrations)-1].version
+}
+
+// rebind rewrites ? placeholders for the backend.
+func (m *Migrator) rebind(query string) string {
+	if !m.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MigrationState is a migration and when it was applied (zero when pending).
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL -- RFC3339
+	);`)
+	return err
+}
+
+// version returns the highest applied migration, 0 for a new database.
+func (m *Migrator) version(ctx context.Context) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	var v int
+	err := m.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
+
+// checkVersion refuses databases written by a newer release, whose schema this
+// binary does not know.
+func (m *Migrator) checkVersion(ctx context.Context) (int, error) {
+	v, err := m.version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if v > m.latest() {
+		return v, fmt.Errorf("database schema version %d is newer than this release supports (%d); upgrade BeerBot", v, m.latest())
+	}
+	return v, nil
+}
+
+// pending returns the migrations that Up would apply.
+func (m *Migrator) pending(ctx context.Context) ([]migration, error) {
+	v, err := m.checkVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []migration
+	for _, mg := range m.migrations {
+		if mg.version > v {
+			out = append(out, mg)
+		}
+	}
+	return out, nil
+}
+
+// Up applies all pending migrations in order. Canceling ctx rolls back the
+// migration in progress; those already applied stay.
+func (m *Migrator) Up(ctx context.Context) error {
+	if m.postgres {
+		release, err := lockMigrations(ctx, m.db)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	pending, err := m.pending(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mg := range pending {
+		err := inTx(ctx, m.db, func(tx *sql.Tx) error {
+			if err := mg.up(ctx, tx); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`), mg.version, mg.name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", mg.version, mg.name, err)
+		}
+	}
+	return nil
+}
+
+// DownTo reverts applied migrations, newest first, until the schema is at the
+// target version.
+func (m *Migrator) DownTo(ctx context.Context, target int) error {
+	v, err := m.checkVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if target < 0 || target > v {
+		return fmt.Errorf("cannot migrate down to version %d: schema is at version %d", target, v)
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if mg.version <= target || mg.version > v {
+			continue
+		}
+		err := inTx(ctx, m.db, func(tx *sql.Tx) error {
+			if err := mg.down(ctx, tx); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.rebind(`DELETE FROM schema_migrations WHERE version = ?`), mg.version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("revert migration %d (%s): %w", mg.version, mg.name, err)
+		}
+	}
+	return nil
+}
+
+// Status lists every known migration plus applied versions this binary does not
+// know about.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationState, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]MigrationState{}
+	var unknown []MigrationState
+	for rows.Next() {
+		var st MigrationState
+		var at string
+		if err := rows.Scan(&st.Version, &st.Name, &at); err != nil {
+			return nil, err
+		}
+		st.AppliedAt, _ = time.Parse(time.RFC3339, at)
+		applied[st.Version] = st
+		if st.Version > m.latest() {
+			unknown = append(unknown, st)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var out []MigrationState
+	for _, mg := range m.migrations {
+		st := MigrationState{Version: mg.version, Name: mg.name}
+		if a, ok := applied[mg.version]; ok {
+			st.AppliedAt = a.AppliedAt
+		}
+		out = append(out, st)
+	}
+	return append(out, unknown...), nil
+}
+
+// RunMigrateCommand implements "beerbot migrate status|up|down-to N". Going
+// below version 1 drops every table, so "down-to 0" also needs --force.
+func RunMigrateCommand(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	switch args[0] {
+	case "status":
+	case "up":
+		if err := m.Up(ctx); err != nil {
+			return err
+		}
+	case "down-to":
+		force := len(args) == 3 && args[2] == "--force"
+		if len(args) != 2 && !force {
+			return fmt.Errorf("usage: migrate down-to VERSION [--force]")
+		}
+		target, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if target < 1 && !force {
+			return fmt.Errorf("migrating down to version %d drops all data; pass --force to confirm", target)
+		}
+		if err := m.DownTo(ctx, target); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q: use status, up or down-to VERSION", args[0])
+	}
+	states, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, st := range states {
+		applied := "pending"
+		if !st.AppliedAt.IsZero() {
+			applied = "applied " + st.AppliedAt.Format(time.RFC3339)
+		}
+		if st.Version > m.latest() {
+			applied += " (unknown to this release)"
+		}
+		fmt.Fprintf(out, "%3d  %-30s  %s\n", st.Version, applied, st.Name)
+	}
+	return nil
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execUp(stmts ...string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, st := range stmts {
+			if _, err := tx.ExecContext(ctx, st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func dropTables(tables ...string) func(ctx context.Context, tx *sql.Tx) error {
+	var stmts []string
+	for _, t := range tables {
+		stmts = append(stmts, `DROP TABLE IF EXISTS `+t+`;`)
+	}
+	return execUp(stmts...)
+}
+
+func dropColumns(table string, columns ...string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, c := range columns {
+			if _, err := tx.ExecContext(ctx, `ALTER TABLE `+table+` DROP COLUMN `+c+`;`); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func tableExists(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	return n > 0, err
+}
+
+// addColumn adds a column unless the table already has it.
+func addColumn(ctx context.Context, tx *sql.Tx, table, column, definition string) error {
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+definition+`;`)
+	return err
+}
+
+// hasUniqueIndex reports whether the table has a unique index (or constraint) on
+// exactly the given columns, in order.
+func hasUniqueIndex(ctx context.Context, tx *sql.Tx, table string, columns ...string) (bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM pragma_index_list(?) WHERE "unique" = 1`, table)
+	if err != nil {
+		return false, err
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return false, err
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	want := strings.Join(columns, ",")
+	for _, idx := range indexes {
+		var got string
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(group_concat(name, ','), '') FROM (SELECT name FROM pragma_index_info(?) ORDER BY seqno)`, idx).Scan(&got); err != nil {
+			return false, err
+		}
+		if got == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const baselineBeers = `CREATE TABLE beers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		giver_id TEXT NOT NULL,
+		recipient_id TEXT NOT NULL,
+		ts TEXT NOT NULL, -- original Slack ts string (with fraction)
+		ts_rfc DATETIME NOT NULL, -- parsed RFC3339 time for date queries
+		count INTEGER NOT NULL DEFAULT 1,
+		UNIQUE (giver_id, recipient_id, ts)
+	);`
+
+const baselineAudit = `CREATE TABLE beer_events_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id TEXT NOT NULL,
+		giver_id TEXT NOT NULL,
+		recipient_id TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		status TEXT NOT NULL, -- success|duplicate|invalid_recipient|self_gift|over_budget|amended|revoked|undone|granted|error
+		ts_rfc DATETIME NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(event_id, recipient_id)
+	);`
+
+// migrateBaselineUp creates the original tables. Pre-versioning databases are
+// brought to the same shape: very old beers tables without the per-message
+// uniqueness are rebuilt with their rows aggregated, and audit tables that were
+// unique per event become unique per (event, recipient).
+func migrateBaselineUp(ctx context.Context, tx *sql.Tx) error {
+	err := execUp(
+		`CREATE TABLE IF NOT EXISTS emoji_counts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			emoji TEXT NOT NULL,
+			count INTEGER NOT NULL DEFAULT 0,
+			UNIQUE(user_id, emoji)
+		);`,
+		`CREATE TABLE IF NOT EXISTS processed_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL UNIQUE,
+			ts TEXT NOT NULL
+		);`,
+	)(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	exists, err := tableExists(ctx, tx, "beers")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := tx.ExecContext(ctx, baselineBeers); err != nil {
+			return err
+		}
+	} else {
+		if err := addColumn(ctx, tx, "beers", "ts_rfc", "DATETIME"); err != nil {
+			return err
+		}
+		if err := addColumn(ctx, tx, "beers", "count", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+			return err
+		}
+		unique, err := hasUniqueIndex(ctx, tx, "beers", "giver_id", "recipient_id", "ts")
+		if err != nil {
+			return err
+		}
+		if !unique {
+			err := execUp(
+				`ALTER TABLE beers RENAME TO beers_legacy;`,
+				baselineBeers,
+				`INSERT INTO beers (giver_id, recipient_id, ts, ts_rfc, count)
+					SELECT giver_id, recipient_id, ts,
+						COALESCE(MAX(ts_rfc), strftime('%Y-%m-%dT%H:%M:%SZ', CAST(substr(ts, 1, instr(ts || '.', '.') - 1) AS INTEGER), 'unixepoch')),
+						SUM(COALESCE(count, 1))
+					FROM beers_legacy GROUP BY giver_id, recipient_id, ts;`,
+				`DROP TABLE beers_legacy;`,
+			)(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("rebuild legacy beers: %w", err)
+			}
+		}
+	}
+
+	exists, err = tableExists(ctx, tx, "beer_events_audit")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := tx.ExecContext(ctx, baselineAudit); err != nil {
+			return err
+		}
+	} else if perEvent, err := hasUniqueIndex(ctx, tx, "beer_events_audit", "event_id"); err != nil {
+		return err
+	} else if perEvent {
+		err := execUp(
+			`ALTER TABLE beer_events_audit RENAME TO beer_events_audit_legacy;`,
+			baselineAudit,
+			`INSERT INTO beer_events_audit (id, event_id, giver_id, recipient_id, quantity, status, ts_rfc, created_at)
+				SELECT id, event_id, giver_id, recipient_id, quantity, status, ts_rfc, created_at FROM beer_events_audit_legacy;`,
+			`DROP TABLE beer_events_audit_legacy;`,
+		)(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("rebuild legacy audit: %w", err)
+		}
+	}
+
+	return execUp(
+		`CREATE INDEX IF NOT EXISTS idx_beers_giver_id_ts_rfc ON beers (giver_id, ts_rfc);`,
+		`CREATE INDEX IF NOT EXISTS idx_beers_recipient_id_ts_rfc ON beers (recipient_id, ts_rfc);`,
+		`CREATE INDEX IF NOT EXISTS idx_beers_emoji_ts_rfc ON beers (ts_rfc);`,
+		`CREATE INDEX IF NOT EXISTS idx_emoji_counts_user_id_emoji ON emoji_counts (user_id, emoji);`,
+	)(ctx, tx)
+}
+
+// migrateLocalDayUp adds the day_local column used by all date queries. Rows
+// written before time-zone support are backfilled with their UTC day.
+func migrateLocalDayUp(ctx context.Context, tx *sql.Tx) error {
+	if err := addColumn(ctx, tx, "beers", "day_local", "TEXT"); err != nil {
+		return err
+	}
+	return execUp(
+		`UPDATE beers SET day_local = substr(ts_rfc, 1, 10) WHERE day_local IS NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_beers_giver_id_day_local ON beers (giver_id, day_local);`,
+		`CREATE INDEX IF NOT EXISTS idx_beers_recipient_id_day_local ON beers (recipient_id, day_local);`,
+		`CREATE INDEX IF NOT EXISTS idx_beers_day_local ON beers (day_local);`,
+	)(ctx, tx)
+}
+
+func migrateLocalDayDown(ctx context.Context, tx *sql.Tx) error {
+	err := execUp(
+		`DROP INDEX IF EXISTS idx_beers_giver_id_day_local;`,
+		`DROP INDEX IF EXISTS idx_beers_recipient_id_day_local;`,
+		`DROP INDEX IF EXISTS idx_beers_day_local;`,
+	)(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return dropColumns("beers", "day_local")(ctx, tx)
+}
+
+// giftDetailColumns are the beers columns added by migration 4, in order.
+var giftDetailColumns = []string{"reason", "channel_id", "permalink", "revoked_at", "confirm_channel", "confirm_ts"}
+
+func migrateGiftDetailsUp(ctx context.Context, tx *sql.Tx) error {
+	definitions := map[string]string{
+		"reason":          `TEXT NOT NULL DEFAULT ''`, // free text around the gift ("for fixing the prod outage")
+		"channel_id":      `TEXT NOT NULL DEFAULT ''`,
+		"permalink":       `TEXT NOT NULL DEFAULT ''`,
+		"revoked_at":      `DATETIME`,                 // set when the gift was undone; revoked rows are not counted
+		"confirm_channel": `TEXT NOT NULL DEFAULT ''`, // where the bot confirmed the gift
+		"confirm_ts":      `TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, col := range giftDetailColumns {
+		if err := addColumn(ctx, tx, "beers", col, definitions[col]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "beerbot.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrations_UpDownStatus(t *testing.T) {
+	ctx := t.Context()
+	db := openTestDB(t)
+	m := NewSQLiteMigrator(db)
+	if _, err := NewSQLiteStore(ctx, db); err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if v, _ := m.version(ctx); v != m.latest() {
+		t.Fatalf("expected version %d, got %d", m.latest(), v)
+	}
+	// A second start is a no-op
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+
+	if err := m.DownTo(ctx, 3); err != nil {
+		t.Fatalf("down-to 3: %v", err)
+	}
+	var n int
+	db.QueryRow(`SELECT COUNT(1) FROM pragma_table_info('beers') WHERE name = 'reason'`).Scan(&n)
+	if n != 0 {
+		t.Fatalf("expected reason column dropped")
+	}
+	db.QueryRow(`SELECT COUNT(1) FROM sqlite_master WHERE name = 'settings'`).Scan(&n)
+	if n != 0 {
+		t.Fatalf("expected settings table dropped")
+	}
+
+	var out strings.Builder
+	if err := RunMigrateCommand(ctx, m, []string{"status"}, &out); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !strings.Contains(out.String(), "applied") || !strings.Contains(out.String(), "pending") {
+		t.Fatalf("unexpected status:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := RunMigrateCommand(ctx, m, []string{"up"}, &out); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if strings.Contains(out.String(), "pending") {
+		t.Fatalf("expected all applied:\n%s", out.String())
+	}
+	s := &SQLiteStore{db: db}
+	if err := s.AddBeer(ctx, "U1", "U2", "1700000000.000100", time.Now(), 1, BeerNote{Reason: "for the review"}); err != nil {
+		t.Fatalf("add beer after re-upgrade: %v", err)
+	}
+	if err := RunMigrateCommand(ctx, m, []string{"down-to", "99"}, &out); err == nil {
+		t.Fatalf("expected error migrating down to a newer version")
+	}
+	if err := RunMigrateCommand(ctx, m, []string{"down-to", "0"}, &out); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected down-to 0 to require --force, got %v", err)
+	}
+	if v, _ := m.version(ctx); v != m.latest() {
+		t.Fatalf("expected schema kept at version %d, got %d", m.latest(), v)
+	}
+
+	// Databases from before versioning have every table but no history
+	if _, err := db.Exec(`DROP TABLE schema_migrations`); err != nil {
+		t.Fatalf("drop schema_migrations: %v", err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("adopt unversioned schema: %v", err)
+	}
+	if given, _, err := s.UserTotals(ctx, "U1", time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 1)); err != nil || given != 1 {
+		t.Fatalf("expected gift kept after adoption, got %d %v", given, err)
+	}
+}
+
+func TestMigrations_RefuseNewerSchema(t *testing.T) {
+	ctx := t.Context()
+	db := openTestDB(t)
+	m := NewSQLiteMigrator(db)
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from the future', ?)`, m.latest()+1, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		t.Fatalf("insert future migration: %v", err)
+	}
+	if _, err := NewSQLiteStore(ctx, db); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("expected newer schema error, got %v", err)
+	}
+	states, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if last := states[len(states)-1]; last.Version != m.latest()+1 || last.Name != "from the future" {
+		t.Fatalf("expected unknown migration listed last, got %+v", last)
+	}
+}
+
+func TestMigrations_CanceledContext(t *testing.T) {
+	db := openTestDB(t)
+	m := NewSQLiteMigrator(db)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := NewSQLiteStore(ctx, db); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if v, err := m.version(t.Context()); err != nil || v != 0 {
+		t.Fatalf("expected no migration applied, got version %d (%v)", v, err)
+	}
+}
+
+func TestMigrations_AdoptLegacyBeers(t *testing.T) {
+	ctx := t.Context()
+	db := openTestDB(t)
+	// earliest schema: no ts_rfc, no count and no per-message uniqueness
+	if _, err := db.Exec(`CREATE TABLE beers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		giver_id TEXT NOT NULL,
+		recipient_id TEXT NOT NULL,
+		ts TEXT NOT NULL
+	);`); err != nil {
+		t.Fatalf("create legacy beers: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(`INSERT INTO beers (giver_id, recipient_id, ts) VALUES ('U1', 'U2', '1704110400.000100')`); err != nil {
+			t.Fatalf("seed legacy beers: %v", err)
+		}
+	}
+
+	if _, err := NewSQLiteStore(ctx, db); err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	var count int
+	var tsRFC, day string
+	if err := db.QueryRow(`SELECT count, ts_rfc, day_local FROM beers WHERE giver_id = 'U1'`).Scan(&count, &tsRFC, &day); err != nil {
+		t.Fatalf("read migrated beer: %v", err)
+	}
+	if count != 2 || tsRFC != "2024-01-01T12:00:00Z" || day != "2024-01-01" {
+		t.Fatalf("unexpected migrated row: count=%d ts_rfc=%s day=%s", count, tsRFC, day)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	if ok, err := hasUniqueIndex(ctx, tx, "beers", "giver_id", "recipient_id", "ts"); err != nil || !ok {
+		t.Fatalf("expected unique (giver_id, recipient_id, ts), got %v %v", ok, err)
+	}
+}
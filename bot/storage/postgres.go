@@ -0,0 +1,521 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers PostgresDriver
+)
+
+// PostgresDriver is the database/sql driver used for DB_DRIVER=postgres.
+const PostgresDriver = "pgx"
+
+// PostgresStore implements Store on PostgreSQL, so several bot replicas can share
+// one database. Semantics match SQLiteStore; times are stored as timestamptz and
+// local calendar days as date.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore opens the store, applying pending schema migrations within
+// ctx. It refuses databases whose schema is newer than this release.
+func NewPostgresStore(ctx context.Context, db *sql.DB) (*PostgresStore, error) {
+	if err := NewPostgresMigrator(db).Up(ctx); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// NewPostgresMigrator returns the migrator for a PostgreSQL database.
+func NewPostgresMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db, migrations: postgresMigrations, postgres: true}
+}
+
+// postgresMigrations is the ordered PostgreSQL schema history. It starts at the
+// schema SQLite reached in its migration 7.
+var postgresMigrations = []migration{
+	{1, "initial schema", execUp(
+		`CREATE TABLE emoji_counts (
+			id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			emoji TEXT NOT NULL,
+			count INTEGER NOT NULL DEFAULT 0,
+			UNIQUE(user_id, emoji)
+		);`,
+		`CREATE TABLE processed_events (
+			id BIGSERIAL PRIMARY KEY,
+			event_id TEXT NOT NULL UNIQUE,
+			ts TIMESTAMPTZ NOT NULL
+		);`,
+		`CREATE TABLE beers (
+			id BIGSERIAL PRIMARY KEY,
+			giver_id TEXT NOT NULL,
+			recipient_id TEXT NOT NULL,
+			ts TEXT NOT NULL, -- original Slack ts string (with fraction)
+			ts_rfc TIMESTAMPTZ NOT NULL,
+			count INTEGER NOT NULL DEFAULT 1,
+			day_local DATE NOT NULL, -- calendar day of the gift in the giver's time zone
+			reason TEXT NOT NULL DEFAULT '',
+			channel_id TEXT NOT NULL DEFAULT '',
+			permalink TEXT NOT NULL DEFAULT '',
+			revoked_at TIMESTAMPTZ, -- set when the gift was undone; revoked rows are not counted
+			confirm_channel TEXT NOT NULL DEFAULT '',
+			confirm_ts TEXT NOT NULL DEFAULT '',
+			UNIQUE (giver_id, recipient_id, ts)
+		);`,
+		`CREATE INDEX idx_beers_giver_id_day_local ON beers (giver_id, day_local);`,
+		`CREATE INDEX idx_beers_recipient_id_day_local ON beers (recipient_id, day_local);`,
+		`CREATE INDEX idx_beers_day_local ON beers (day_local);`,
+		`CREATE INDEX idx_beers_giver_id_ts_rfc ON beers (giver_id, ts_rfc);`,
+		`CREATE TABLE beer_events_audit (
+			id BIGSERIAL PRIMARY KEY,
+			event_id TEXT NOT NULL,
+			giver_id TEXT NOT NULL,
+			recipient_id TEXT NOT NULL,
+			quantity INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			ts_rfc TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			UNIQUE(event_id, recipient_id)
+		);`,
+		`CREATE TABLE channel_rules (
+			list TEXT NOT NULL,
+			entry TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (list, entry)
+		);`,
+		`CREATE TABLE settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE TABLE admin_audit (
+			id BIGSERIAL PRIMARY KEY,
+			admin_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			detail TEXT NOT NULL,
+			ts_rfc TIMESTAMPTZ NOT NULL
+		);`,
+		`CREATE TABLE preferences (
+			user_id TEXT PRIMARY KEY,
+			notify TEXT NOT NULL DEFAULT '',
+			digest_sent TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE TABLE digest_runs (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			slot TIMESTAMPTZ NOT NULL,
+			status TEXT NOT NULL,
+			channel_id TEXT NOT NULL DEFAULT '',
+			message_ts TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			UNIQUE(name, slot)
+		);`,
+	), dropTables("digest_runs", "preferences", "admin_audit", "settings", "channel_rules", "beer_events_audit", "beers", "processed_events", "emoji_counts")},
+}
+
+// postgresMigrationLock serializes migrations of replicas starting at the same time.
+const postgresMigrationLock = 0x62656572 // "beer"
+
+// lockMigrations holds a session advisory lock until release is called. Waiting
+// for the lock ends when ctx is done.
+func lockMigrations(ctx context.Context, db *sql.DB) (release func(), err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLock); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("lock migrations: %w", err)
+	}
+	return func() {
+		// Closing the connection also drops the lock should ctx be done by now.
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, postgresMigrationLock)
+		conn.Close()
+	}, nil
+}
+
+func (s *PostgresStore) TryMarkEventProcessed(ctx context.Context, eventID string, ts time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO processed_events (event_id, ts) VALUES ($1, $2) ON CONFLICT (event_id) DO NOTHING`, eventID, ts.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *PostgresStore) UnmarkEventProcessed(ctx context.Context, eventID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM processed_events WHERE event_id = $1`, eventID)
+	return err
+}
+
+func (s *PostgresStore) AddBeer(ctx context.Context, giverID, recipientID string, slackTs string, t time.Time, count int, note BeerNote) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO beers (giver_id, recipient_id, ts, ts_rfc, count, day_local, reason, channel_id, permalink) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (giver_id, recipient_id, ts) DO UPDATE SET count = excluded.count, reason = excluded.reason, channel_id = excluded.channel_id, permalink = excluded.permalink, revoked_at = NULL`,
+		giverID, recipientID, slackTs, t.UTC(), count, t.Format("2006-01-02"), note.Reason, note.ChannelID, note.Permalink)
+	return err
+}
+
+func (s *PostgresStore) RevokeBeer(ctx context.Context, giverID, recipientID string, slackTs string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE beers SET revoked_at = $1 WHERE giver_id = $2 AND recipient_id = $3 AND ts = $4 AND revoked_at IS NULL`, at.UTC(), giverID, recipientID, slackTs)
+	return err
+}
+
+func (s *PostgresStore) SetConfirmation(ctx context.Context, giverID string, slackTs string, channel, confirmTs string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE beers SET confirm_channel = $1, confirm_ts = $2 WHERE giver_id = $3 AND ts = $4`, channel, confirmTs, giverID, slackTs)
+	return err
+}
+
+func (s *PostgresStore) LatestGift(ctx context.Context, giverID string) (*GiftRef, error) {
+	return s.giftRef(ctx, `SELECT ts, ts_rfc, confirm_channel, confirm_ts FROM beers WHERE giver_id = $1 AND revoked_at IS NULL ORDER BY ts_rfc DESC, id DESC LIMIT 1`, giverID)
+}
+
+func (s *PostgresStore) GiftAt(ctx context.Context, giverID string, slackTs string) (*GiftRef, error) {
+	return s.giftRef(ctx, `SELECT ts, ts_rfc, confirm_channel, confirm_ts FROM beers WHERE giver_id = $1 AND ts = $2 AND revoked_at IS NULL ORDER BY id LIMIT 1`, giverID, slackTs)
+}
+
+// giftRef scans the single gift row a LatestGift or GiftAt query selects.
+func (s *PostgresStore) giftRef(ctx context.Context, query string, args ...interface{}) (*GiftRef, error) {
+	var ref GiftRef
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&ref.TS, &ref.Time, &ref.ConfirmChannel, &ref.ConfirmTS)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ref.Time = ref.Time.UTC()
+	return &ref, nil
+}
+
+func (s *PostgresStore) GetBeersByTS(ctx context.Context, giverID string, slackTs string) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT recipient_id, count FROM beers WHERE giver_id = $1 AND ts = $2 AND revoked_at IS NULL`, giverID, slackTs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int{}
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		out[id] = count
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) RecentBeers(ctx context.Context, user string, limit int) ([]BeerRecord, error) {
+	if user == "" {
+		return s.recentBeers(ctx, ``, limit)
+	}
+	return s.recentBeers(ctx, ` AND (giver_id = $2 OR recipient_id = $2)`, limit, user)
+}
+
+func (s *PostgresStore) RecentReceived(ctx context.Context, recipientID string, limit int) ([]BeerRecord, error) {
+	return s.recentBeers(ctx, ` AND recipient_id = $2`, limit, recipientID)
+}
+
+// recentBeers lists gifts newest first; the limit is $1 and filter arguments start
+// at $2. A negative limit returns every row.
+func (s *PostgresStore) recentBeers(ctx context.Context, filter string, limit int, args ...interface{}) ([]BeerRecord, error) {
+	var lim interface{} = limit
+	if limit < 0 {
+		lim = nil // LIMIT NULL is no limit
+	}
+	q := `SELECT giver_id, recipient_id, count, ts, ts_rfc, day_local::text, reason, channel_id, permalink FROM beers WHERE revoked_at IS NULL` +
+		filter + ` ORDER BY ts_rfc DESC, id DESC LIMIT $1`
+	rows, err := s.db.QueryContext(ctx, q, append([]interface{}{lim}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BeerRecord
+	for rows.Next() {
+		var r BeerRecord
+		if err := rows.Scan(&r.Giver, &r.Recipient, &r.Count, &r.TS, &r.Time, &r.Day, &r.Reason, &r.ChannelID, &r.Permalink); err != nil {
+			return nil, err
+		}
+		r.Time = r.Time.UTC()
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) ReceivedSince(ctx context.Context, recipientID string, since time.Time) ([]BeerRecord, error) {
+	out, err := s.recentBeers(ctx, ` AND recipient_id = $2 AND ts_rfc > $3`, -1, recipientID, since.UTC())
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, err
+}
+
+func (s *PostgresStore) RecordBeerEventOutcome(ctx context.Context, eventID, giverID, recipientID string, quantity int, status string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO beer_events_audit (event_id, giver_id, recipient_id, quantity, status, ts_rfc) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (event_id, recipient_id) DO UPDATE SET giver_id = excluded.giver_id, quantity = excluded.quantity, status = excluded.status, ts_rfc = excluded.ts_rfc
+		WHERE excluded.status <> 'duplicate'`, eventID, giverID, recipientID, quantity, status, t.UTC())
+	return err
+}
+
+func (s *PostgresStore) TopGivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error) {
+	return s.totals(ctx, `SELECT giver_id, SUM(count) AS total FROM beers WHERE day_local BETWEEN $1 AND $2 AND revoked_at IS NULL AND giver_id <> $4 GROUP BY giver_id ORDER BY total DESC, giver_id LIMIT $3`,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), limitOr5(limit), GrantGiver)
+}
+
+func (s *PostgresStore) TopReceivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error) {
+	return s.totals(ctx, `SELECT recipient_id, SUM(count) AS total FROM beers WHERE day_local BETWEEN $1 AND $2 AND revoked_at IS NULL GROUP BY recipient_id ORDER BY total DESC, recipient_id LIMIT $3`,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), limitOr5(limit))
+}
+
+func (s *PostgresStore) TopRecipientsOf(ctx context.Context, giverID string, limit int) ([][2]string, error) {
+	return s.totals(ctx, `SELECT recipient_id, SUM(count) AS total FROM beers WHERE giver_id = $1 AND revoked_at IS NULL GROUP BY recipient_id ORDER BY total DESC, recipient_id LIMIT $2`, giverID, limitOr5(limit))
+}
+
+func (s *PostgresStore) TopGiversTo(ctx context.Context, recipientID string, limit int) ([][2]string, error) {
+	return s.totals(ctx, `SELECT giver_id, SUM(count) AS total FROM beers WHERE recipient_id = $1 AND revoked_at IS NULL AND giver_id <> $3 GROUP BY giver_id ORDER BY total DESC, giver_id LIMIT $2`, recipientID, limitOr5(limit), GrantGiver)
+}
+
+func limitOr5(limit int) int {
+	if limit <= 0 {
+		return 5
+	}
+	return limit
+}
+
+// totals scans (user, total) rows as returned by the leaderboard queries.
+func (s *PostgresStore) totals(ctx context.Context, query string, args ...interface{}) ([][2]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][2]string
+	for rows.Next() {
+		var id string
+		var total int
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		out = append(out, [2]string{id, fmt.Sprintf("%d", total)})
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) UserTotals(ctx context.Context, userID string, start, end time.Time) (given int, received int, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT
+			COALESCE(SUM(CASE WHEN giver_id = $1 THEN count ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN recipient_id = $1 THEN count ELSE 0 END), 0)
+		FROM beers WHERE (giver_id = $1 OR recipient_id = $1) AND day_local BETWEEN $2 AND $3 AND revoked_at IS NULL`,
+		userID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&given, &received)
+	return given, received, err
+}
+
+func (s *PostgresStore) GivingDays(ctx context.Context, giverID string, limit int) ([]string, error) {
+	return s.column(ctx, `SELECT DISTINCT day_local::text AS day FROM beers WHERE giver_id = $1 AND revoked_at IS NULL ORDER BY day DESC LIMIT $2`, giverID, limit)
+}
+
+func (s *PostgresStore) TotalBeers(ctx context.Context, start, end time.Time) (int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(count), 0) FROM beers WHERE day_local BETWEEN $1 AND $2 AND revoked_at IS NULL`,
+		start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&total)
+	return total, err
+}
+
+func (s *PostgresStore) Newcomers(ctx context.Context, start, end time.Time) ([]string, error) {
+	return s.column(ctx, `SELECT user_id FROM (
+			SELECT giver_id AS user_id, day_local FROM beers WHERE revoked_at IS NULL AND giver_id <> $3
+			UNION ALL
+			SELECT recipient_id, day_local FROM beers WHERE revoked_at IS NULL
+		) AS gifts GROUP BY user_id HAVING MIN(day_local) BETWEEN $1 AND $2 ORDER BY MIN(day_local), user_id`,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), GrantGiver)
+}
+
+// column scans a single text column.
+func (s *PostgresStore) column(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) CountGivenInDateRange(ctx context.Context, giverID string, start time.Time, end time.Time) (int, error) {
+	var c int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(count), 0) FROM beers WHERE giver_id = $1 AND day_local BETWEEN $2 AND $3 AND revoked_at IS NULL`,
+		giverID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&c)
+	return c, err
+}
+
+func (s *PostgresStore) CountReceivedInDateRange(ctx context.Context, recipientID string, start time.Time, end time.Time) (int, error) {
+	var c int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(count), 0) FROM beers WHERE recipient_id = $1 AND day_local BETWEEN $2 AND $3 AND revoked_at IS NULL`,
+		recipientID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&c)
+	return c, err
+}
+
+func (s *PostgresStore) CountGivenOnDate(ctx context.Context, giverID string, date string) (int, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+	return s.CountGivenInDateRange(ctx, giverID, t, t)
+}
+
+func (s *PostgresStore) GetAllGivers(ctx context.Context) ([]string, error) {
+	return s.column(ctx, `SELECT DISTINCT giver_id FROM beers WHERE revoked_at IS NULL AND giver_id <> $1`, GrantGiver)
+}
+
+func (s *PostgresStore) GetAllRecipients(ctx context.Context) ([]string, error) {
+	return s.column(ctx, `SELECT DISTINCT recipient_id FROM beers WHERE revoked_at IS NULL`)
+}
+
+func (s *PostgresStore) GetChannelRules(ctx context.Context) ([]ChannelRule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT list, entry FROM channel_rules ORDER BY list, entry`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChannelRule
+	for rows.Next() {
+		var r ChannelRule
+		if err := rows.Scan(&r.List, &r.Entry); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) AddChannelRule(ctx context.Context, list, entry string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO channel_rules (list, entry) VALUES ($1, $2) ON CONFLICT DO NOTHING`, list, entry)
+	return err
+}
+
+func (s *PostgresStore) RemoveChannelRule(ctx context.Context, list, entry string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM channel_rules WHERE list = $1 AND entry = $2`, list, entry)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *PostgresStore) GetSettings(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) SetSetting(ctx context.Context, key, value string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, key, value)
+	return err
+}
+
+func (s *PostgresStore) RecordAdminAction(ctx context.Context, admin, action, detail string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO admin_audit (admin_id, action, detail, ts_rfc) VALUES ($1, $2, $3, $4)`, admin, action, detail, t.UTC())
+	return err
+}
+
+func (s *PostgresStore) AdminActions(ctx context.Context, limit int) ([]AdminAction, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT admin_id, action, detail, ts_rfc FROM admin_audit ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AdminAction
+	for rows.Next() {
+		var a AdminAction
+		if err := rows.Scan(&a.Admin, &a.Action, &a.Detail, &a.Time); err != nil {
+			return nil, err
+		}
+		a.Time = a.Time.UTC()
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) GetPreferences(ctx context.Context, userID string) (Preferences, error) {
+	p := Preferences{User: userID}
+	var sent sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT notify, digest_sent FROM preferences WHERE user_id = $1`, userID).Scan(&p.Notify, &sent)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if sent.Valid {
+		p.DigestSent = sent.Time.UTC()
+	}
+	return p, err
+}
+
+func (s *PostgresStore) ListPreferences(ctx context.Context) ([]Preferences, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, notify, digest_sent FROM preferences ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Preferences
+	for rows.Next() {
+		var p Preferences
+		var sent sql.NullTime
+		if err := rows.Scan(&p.User, &p.Notify, &sent); err != nil {
+			return nil, err
+		}
+		if sent.Valid {
+			p.DigestSent = sent.Time.UTC()
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) SetNotify(ctx context.Context, userID, notify string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO preferences (user_id, notify, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (user_id) DO UPDATE SET notify = excluded.notify, updated_at = excluded.updated_at`, userID, notify)
+	return err
+}
+
+func (s *PostgresStore) SetDigestSent(ctx context.Context, userID string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO preferences (user_id, digest_sent) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET digest_sent = excluded.digest_sent`, userID, t.UTC())
+	return err
+}
+
+func (s *PostgresStore) ClaimDigestRun(ctx context.Context, name string, slot time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO digest_runs (name, slot, status) VALUES ($1, $2, 'running') ON CONFLICT (name, slot) DO NOTHING`, name, slot.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *PostgresStore) FinishDigestRun(ctx context.Context, name string, slot time.Time, status, channel, ts string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE digest_runs SET status = $1, channel_id = $2, message_ts = $3 WHERE name = $4 AND slot = $5`,
+		status, channel, ts, name, slot.UTC())
+	return err
+}
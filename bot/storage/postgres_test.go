@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+)
+
+func TestPostgresStore_RecordBeerEventOutcome(t *testing.T) {
+	ctx := t.Context()
+	dsn := os.Getenv("BEERBOT_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		dsn = startFakePostgres(t)
+	}
+	db := openPostgresSchema(t, dsn)
+	s, err := storage.NewPostgresStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	now := time.Now()
+	for _, o := range []struct{ event, recipient, status string }{
+		{"E1", "R1", "success"},
+		{"E1", "R1", "duplicate"}, // redelivery: keeps the success
+		{"E2", "R1", "error"},
+		{"E2", "R1", "undone"}, // retry: replaces the error
+	} {
+		if err := s.RecordBeerEventOutcome(ctx, o.event, "G", o.recipient, 1, o.status, now); err != nil {
+			t.Fatalf("record %+v: %v", o, err)
+		}
+	}
+	rows, err := db.QueryContext(ctx, `SELECT event_id, status FROM beer_events_audit ORDER BY event_id`)
+	if err != nil {
+		t.Fatalf("query audit: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var event, status string
+		if err := rows.Scan(&event, &status); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, event+":"+status)
+	}
+	if strings.Join(got, ",") != "E1:success,E2:undone" {
+		t.Fatalf("unexpected outcomes %v", got)
+	}
+}
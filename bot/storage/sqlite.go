@@ -0,0 +1,618 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens the store, applying pending schema migrations within
+// ctx. It refuses databases whose schema is newer than this release.
+func NewSQLiteStore(ctx context.Context, db *sql.DB) (*SQLiteStore, error) {
+	if err := NewSQLiteMigrator(db).Up(ctx); err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// MarkEventProcessed records that an external event (by event_id) has been
+// handled. Returns nil if inserted; if the event already exists, returns nil as well.
+func (s *SQLiteStore) MarkEventProcessed(ctx context.Context, eventID string, ts time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO processed_events (event_id, ts) VALUES (?, ?);`, eventID, ts.UTC().Format(time.RFC3339))
+	return err
+}
+
+// TryMarkEventProcessed attempts to insert the event id into processed_events.
+// Returns (true, nil) if we recorded the event (i.e. this process should handle it),
+// (false, nil) if the event was already present (another process handled it),
+// or (false, err) on database error.
+func (s *SQLiteStore) TryMarkEventProcessed(ctx context.Context, eventID string, ts time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO processed_events (event_id, ts) VALUES (?, ?);`, eventID, ts.UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// UnmarkEventProcessed forgets a processed event so a redelivery of it is handled
+// again. It is used when handling the event was cut short.
+func (s *SQLiteStore) UnmarkEventProcessed(ctx context.Context, eventID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM processed_events WHERE event_id = ?`, eventID)
+	return err
+}
+
+// IsEventProcessed returns true if we've already processed the given event id.
+func (s *SQLiteStore) IsEventProcessed(ctx context.Context, eventID string) (bool, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM processed_events WHERE event_id = ?`, eventID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQLiteStore) IncEmoji(ctx context.Context, userID, emoji string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// try update
+	res, err := tx.ExecContext(ctx, `UPDATE emoji_counts SET count = count + 1 WHERE user_id = ? AND emoji = ?`, userID, emoji)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO emoji_counts(user_id, emoji, count) VALUES(?, ?, 1)`, userID, emoji); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetCount(ctx context.Context, userID, emoji string) (int, error) {
+	var c int
+	err := s.db.QueryRowContext(ctx, `SELECT count FROM emoji_counts WHERE user_id = ? AND emoji = ?`, userID, emoji).Scan(&c)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return c, nil
+}
+
+// AddBeer records a beer-gift event for a single message: it inserts or upserts
+// a row with the provided count keyed by the original Slack ts string (ts).
+// If the same (giver, recipient, ts) already exists, the count and note will be
+// updated to the provided values (last write wins) and an undo is cleared. t should be in the giver's
+// time zone: its calendar day is stored as day_local and drives all date queries.
+func (s *SQLiteStore) AddBeer(ctx context.Context, giverID, recipientID string, slackTs string, t time.Time, count int, note BeerNote) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO beers (giver_id, recipient_id, ts, ts_rfc, count, day_local, reason, channel_id, permalink) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(giver_id, recipient_id, ts) DO UPDATE SET count = excluded.count, reason = excluded.reason, channel_id = excluded.channel_id, permalink = excluded.permalink, revoked_at = NULL`,
+		giverID, recipientID, slackTs, t.UTC().Format(time.RFC3339), count, t.Format("2006-01-02"), note.Reason, note.ChannelID, note.Permalink)
+	return err
+}
+
+// RevokeBeer marks the gift identified by (giver, recipient, ts) as undone. The row is
+// kept for auditing but no longer counted.
+func (s *SQLiteStore) RevokeBeer(ctx context.Context, giverID, recipientID string, slackTs string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE beers SET revoked_at = ? WHERE giver_id = ? AND recipient_id = ? AND ts = ? AND revoked_at IS NULL`, at.UTC().Format(time.RFC3339), giverID, recipientID, slackTs)
+	return err
+}
+
+// SetConfirmation remembers the bot message that confirmed a giver's gift so it can be
+// updated when the gift is undone.
+func (s *SQLiteStore) SetConfirmation(ctx context.Context, giverID string, slackTs string, channel, confirmTs string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE beers SET confirm_channel = ?, confirm_ts = ? WHERE giver_id = ? AND ts = ?`, channel, confirmTs, giverID, slackTs)
+	return err
+}
+
+// LatestGift returns the giver's most recent gift that has not been undone, or nil.
+func (s *SQLiteStore) LatestGift(ctx context.Context, giverID string) (*GiftRef, error) {
+	return s.giftRef(ctx, `SELECT ts, ts_rfc, confirm_channel, confirm_ts FROM beers WHERE giver_id = ? AND revoked_at IS NULL ORDER BY ts_rfc DESC, id DESC LIMIT 1`, giverID)
+}
+
+// GiftAt returns the giver's gift stored under ts while any of it is still
+// active, or nil.
+func (s *SQLiteStore) GiftAt(ctx context.Context, giverID string, slackTs string) (*GiftRef, error) {
+	return s.giftRef(ctx, `SELECT ts, ts_rfc, confirm_channel, confirm_ts FROM beers WHERE giver_id = ? AND ts = ? AND revoked_at IS NULL ORDER BY id LIMIT 1`, giverID, slackTs)
+}
+
+// giftRef scans the single gift row a LatestGift or GiftAt query selects.
+func (s *SQLiteStore) giftRef(ctx context.Context, query string, args ...interface{}) (*GiftRef, error) {
+	var ref GiftRef
+	var tsRFC string
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&ref.TS, &tsRFC, &ref.ConfirmChannel, &ref.ConfirmTS)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t, err := time.Parse(time.RFC3339, tsRFC); err == nil {
+		ref.Time = t
+	}
+	return &ref, nil
+}
+
+// GetBeersByTS returns the recorded gifts of one giver's message as recipient -> count.
+func (s *SQLiteStore) GetBeersByTS(ctx context.Context, giverID string, slackTs string) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT recipient_id, count FROM beers WHERE giver_id = ? AND ts = ? AND revoked_at IS NULL`, giverID, slackTs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int{}
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		out[id] = count
+	}
+	return out, rows.Err()
+}
+
+// RecentBeers returns the latest gifts, newest first. When user is set only gifts
+// given or received by that user are returned.
+func (s *SQLiteStore) RecentBeers(ctx context.Context, user string, limit int) ([]BeerRecord, error) {
+	if user == "" {
+		return s.recentBeers(ctx, ``, limit)
+	}
+	return s.recentBeers(ctx, ` AND (giver_id = ? OR recipient_id = ?)`, limit, user, user)
+}
+
+// RecentReceived returns the latest gifts to the recipient, newest first.
+func (s *SQLiteStore) RecentReceived(ctx context.Context, recipientID string, limit int) ([]BeerRecord, error) {
+	return s.recentBeers(ctx, ` AND recipient_id = ?`, limit, recipientID)
+}
+
+func (s *SQLiteStore) recentBeers(ctx context.Context, filter string, limit int, args ...interface{}) ([]BeerRecord, error) {
+	q := `SELECT giver_id, recipient_id, count, ts, ts_rfc, COALESCE(day_local, ''), reason, channel_id, permalink FROM beers WHERE revoked_at IS NULL` +
+		filter + ` ORDER BY ts_rfc DESC, id DESC LIMIT ?`
+	rows, err := s.db.QueryContext(ctx, q, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []BeerRecord
+	for rows.Next() {
+		var r BeerRecord
+		var tsRFC string
+		if err := rows.Scan(&r.Giver, &r.Recipient, &r.Count, &r.TS, &tsRFC, &r.Day, &r.Reason, &r.ChannelID, &r.Permalink); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, tsRFC); err == nil {
+			r.Time = t
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// ReceivedSince returns the gifts to the recipient recorded after since, oldest first.
+func (s *SQLiteStore) ReceivedSince(ctx context.Context, recipientID string, since time.Time) ([]BeerRecord, error) {
+	out, err := s.recentBeers(ctx, ` AND recipient_id = ? AND ts_rfc > ?`, -1, recipientID, since.UTC().Format(time.RFC3339))
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, err
+}
+
+// RecordBeerEventOutcome stores processing outcome for a beer gift attempt.
+// Outcomes are unique per (event, recipient), so a message that names several
+// recipients records one row for each of them. A later attempt's outcome
+// replaces the earlier one (an "error" followed by a successful retry ends as
+// the success), except "duplicate", which never overwrites what happened.
+func (s *SQLiteStore) RecordBeerEventOutcome(ctx context.Context, eventID, giverID, recipientID string, quantity int, status string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO beer_events_audit (event_id, giver_id, recipient_id, quantity, status, ts_rfc) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (event_id, recipient_id) DO UPDATE SET giver_id = excluded.giver_id, quantity = excluded.quantity, status = excluded.status, ts_rfc = excluded.ts_rfc
+		WHERE excluded.status <> 'duplicate'`, eventID, giverID, recipientID, quantity, status, t.UTC().Format(time.RFC3339))
+	return err
+}
+
+// TopGivers returns top N givers in a date range.
+// Dates are compared as local calendar days (see AddBeer), inclusive on both ends.
+func (s *SQLiteStore) TopGivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	startStr := start.Format("2006-01-02")
+	endStr := end.Format("2006-01-02")
+	rows, err := s.db.QueryContext(ctx, `SELECT giver_id, COALESCE(SUM(count),0) as total FROM beers WHERE day_local BETWEEN ? AND ? AND revoked_at IS NULL AND giver_id <> ? GROUP BY giver_id ORDER BY total DESC, giver_id LIMIT ?`, startStr, endStr, GrantGiver, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][2]string
+	for rows.Next() {
+		var id string
+		var total int
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		out = append(out, [2]string{id, fmt.Sprintf("%d", total)})
+	}
+	return out, nil
+}
+
+// TopReceivers returns top N receivers in a date range.
+func (s *SQLiteStore) TopReceivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	startStr := start.Format("2006-01-02")
+	endStr := end.Format("2006-01-02")
+	rows, err := s.db.QueryContext(ctx, `SELECT recipient_id, COALESCE(SUM(count),0) as total FROM beers WHERE day_local BETWEEN ? AND ? AND revoked_at IS NULL GROUP BY recipient_id ORDER BY total DESC, recipient_id LIMIT ?`, startStr, endStr, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][2]string
+	for rows.Next() {
+		var id string
+		var total int
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		out = append(out, [2]string{id, fmt.Sprintf("%d", total)})
+	}
+	return out, nil
+}
+
+// UserTotals returns how many beers the user gave and received in a date range
+// (local calendar days, inclusive).
+func (s *SQLiteStore) UserTotals(ctx context.Context, userID string, start, end time.Time) (given int, received int, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT
+			COALESCE(SUM(CASE WHEN giver_id = ? THEN count ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN recipient_id = ? THEN count ELSE 0 END), 0)
+		FROM beers WHERE (giver_id = ? OR recipient_id = ?) AND day_local BETWEEN ? AND ? AND revoked_at IS NULL`,
+		userID, userID, userID, userID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&given, &received)
+	return given, received, err
+}
+
+// GivingDays returns the distinct local calendar days (YYYY-MM-DD) on which the
+// giver gave beers, newest first.
+func (s *SQLiteStore) GivingDays(ctx context.Context, giverID string, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT day_local FROM beers WHERE giver_id = ? AND day_local IS NOT NULL AND revoked_at IS NULL ORDER BY day_local DESC LIMIT ?`, giverID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// TotalBeers returns how many beers were given in a date range (local calendar days, inclusive).
+func (s *SQLiteStore) TotalBeers(ctx context.Context, start, end time.Time) (int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(count), 0) FROM beers WHERE day_local BETWEEN ? AND ? AND revoked_at IS NULL`,
+		start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&total)
+	return total, err
+}
+
+// Newcomers returns the users whose first gift, given or received, falls in the
+// date range, earliest first.
+func (s *SQLiteStore) Newcomers(ctx context.Context, start, end time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id FROM (
+			SELECT giver_id AS user_id, day_local FROM beers WHERE revoked_at IS NULL AND giver_id <> ?
+			UNION ALL
+			SELECT recipient_id, day_local FROM beers WHERE revoked_at IS NULL
+		) GROUP BY user_id HAVING MIN(day_local) BETWEEN ? AND ? ORDER BY MIN(day_local), user_id`,
+		GrantGiver, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// TopRecipientsOf returns the people the giver gave the most beers to, all-time.
+func (s *SQLiteStore) TopRecipientsOf(ctx context.Context, giverID string, limit int) ([][2]string, error) {
+	return s.topPartners(ctx, `SELECT recipient_id, SUM(count) AS total FROM beers WHERE giver_id = ? AND revoked_at IS NULL GROUP BY recipient_id ORDER BY total DESC, recipient_id LIMIT ?`, giverID, limit)
+}
+
+// TopGiversTo returns the people who gave the recipient the most beers, all-time.
+func (s *SQLiteStore) TopGiversTo(ctx context.Context, recipientID string, limit int) ([][2]string, error) {
+	return s.topPartners(ctx, `SELECT giver_id, SUM(count) AS total FROM beers WHERE recipient_id = ? AND revoked_at IS NULL AND giver_id <> '`+GrantGiver+`' GROUP BY giver_id ORDER BY total DESC, giver_id LIMIT ?`, recipientID, limit)
+}
+
+func (s *SQLiteStore) topPartners(ctx context.Context, query, userID string, limit int) ([][2]string, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][2]string
+	for rows.Next() {
+		var id string
+		var total int
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		out = append(out, [2]string{id, fmt.Sprintf("%d", total)})
+	}
+	return out, rows.Err()
+}
+
+// CountGivenInDateRange returns how many beers the giver gave in the given date range
+func (s *SQLiteStore) CountGivenInDateRange(ctx context.Context, giverID string, start time.Time, end time.Time) (int, error) {
+	// Compare local calendar days (YYYY-MM-DD) as stored in day_local
+	startStr := start.Format("2006-01-02")
+	endStr := end.Format("2006-01-02")
+
+	var c int
+	query := `SELECT COALESCE(SUM(count), 0) FROM beers WHERE giver_id = ? AND day_local BETWEEN ? AND ? AND revoked_at IS NULL`
+	err := s.db.QueryRowContext(ctx, query, giverID, startStr, endStr).Scan(&c)
+	if err != nil {
+		return 0, err
+	}
+	return c, nil
+}
+
+// CountReceivedInDateRange returns total beers received by recipient in the given date range
+func (s *SQLiteStore) CountReceivedInDateRange(ctx context.Context, recipientID string, start time.Time, end time.Time) (int, error) {
+	var c int
+	// Compare local calendar days (YYYY-MM-DD) as stored in day_local
+	query := `SELECT COALESCE(SUM(count), 0) FROM beers WHERE recipient_id = ? AND day_local BETWEEN ? AND ? AND revoked_at IS NULL`
+	startStr := start.Format("2006-01-02")
+	endStr := end.Format("2006-01-02")
+	err := s.db.QueryRowContext(ctx, query, recipientID, startStr, endStr).Scan(&c)
+	if err != nil {
+		return 0, err
+	}
+	return c, nil
+}
+
+// CountGivenOnDate returns how many beers the giver gave on the given date (YYYY-MM-DD)
+func (s *SQLiteStore) CountGivenOnDate(ctx context.Context, giverID string, date string) (int, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+	return s.CountGivenInDateRange(ctx, giverID, t, t)
+}
+
+// CountReceived returns total beers received by recipient (optionally filtered by date if not empty)
+func (s *SQLiteStore) CountReceived(ctx context.Context, recipientID string, date string) (int, error) {
+	if date == "" {
+		return s.CountReceivedInDateRange(ctx, recipientID, time.Time{}, time.Now())
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+	return s.CountReceivedInDateRange(ctx, recipientID, t, t)
+}
+
+// GetAllGivers returns the list of all distinct user IDs that have given at least one beer.
+func (s *SQLiteStore) GetAllGivers(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT giver_id FROM beers WHERE revoked_at IS NULL AND giver_id <> ?`, GrantGiver)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// GetAllRecipients returns the list of all distinct recipient user IDs that have received at least one beer.
+func (s *SQLiteStore) GetAllRecipients(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT recipient_id FROM beers WHERE revoked_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// GetChannelRules returns all stored channel allow/deny entries.
+func (s *SQLiteStore) GetChannelRules(ctx context.Context) ([]ChannelRule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT list, entry FROM channel_rules ORDER BY list, entry`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChannelRule
+	for rows.Next() {
+		var r ChannelRule
+		if err := rows.Scan(&r.List, &r.Entry); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// AddChannelRule stores a channel allow/deny entry; adding an existing entry is a no-op.
+func (s *SQLiteStore) AddChannelRule(ctx context.Context, list, entry string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO channel_rules (list, entry) VALUES (?, ?)`, list, entry)
+	return err
+}
+
+// RemoveChannelRule deletes a channel allow/deny entry and reports whether it existed.
+func (s *SQLiteStore) RemoveChannelRule(ctx context.Context, list, entry string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM channel_rules WHERE list = ? AND entry = ?`, list, entry)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetSettings returns the runtime settings changed with /beer-admin, by key.
+func (s *SQLiteStore) GetSettings(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, rows.Err()
+}
+
+// SetSetting stores a runtime setting, replacing its previous value.
+func (s *SQLiteStore) SetSetting(ctx context.Context, key, value string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, key, value)
+	return err
+}
+
+// RecordAdminAction appends an entry to the admin audit trail.
+func (s *SQLiteStore) RecordAdminAction(ctx context.Context, admin, action, detail string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO admin_audit (admin_id, action, detail, ts_rfc) VALUES (?, ?, ?, ?)`, admin, action, detail, t.UTC().Format(time.RFC3339))
+	return err
+}
+
+// AdminActions returns the latest admin audit entries, newest first.
+func (s *SQLiteStore) AdminActions(ctx context.Context, limit int) ([]AdminAction, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT admin_id, action, detail, ts_rfc FROM admin_audit ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AdminAction
+	for rows.Next() {
+		var a AdminAction
+		var tsRFC string
+		if err := rows.Scan(&a.Admin, &a.Action, &a.Detail, &tsRFC); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, tsRFC); err == nil {
+			a.Time = t
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// GetPreferences returns the user's preferences; users without stored
+// preferences get empty ones.
+func (s *SQLiteStore) GetPreferences(ctx context.Context, userID string) (Preferences, error) {
+	p := Preferences{User: userID}
+	var sent sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT notify, digest_sent FROM preferences WHERE user_id = ?`, userID).Scan(&p.Notify, &sent)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if sent.Valid {
+		p.DigestSent, _ = time.Parse(time.RFC3339, sent.String)
+	}
+	return p, err
+}
+
+// ListPreferences returns all stored preferences.
+func (s *SQLiteStore) ListPreferences(ctx context.Context) ([]Preferences, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, notify, digest_sent FROM preferences ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Preferences
+	for rows.Next() {
+		var p Preferences
+		var sent sql.NullString
+		if err := rows.Scan(&p.User, &p.Notify, &sent); err != nil {
+			return nil, err
+		}
+		if sent.Valid {
+			p.DigestSent, _ = time.Parse(time.RFC3339, sent.String)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// SetNotify stores how the user wants to be notified about gifts.
+func (s *SQLiteStore) SetNotify(ctx context.Context, userID, notify string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO preferences (user_id, notify, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET notify = excluded.notify, updated_at = excluded.updated_at`, userID, notify)
+	return err
+}
+
+// SetDigestSent records the end of the period covered by the user's last daily digest.
+func (s *SQLiteStore) SetDigestSent(ctx context.Context, userID string, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO preferences (user_id, digest_sent) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET digest_sent = excluded.digest_sent`, userID, t.UTC().Format(time.RFC3339))
+	return err
+}
+
+// ClaimDigestRun records that the digest for a scheduled slot is being posted. It
+// returns false when the slot was already claimed, e.g. before a restart.
+func (s *SQLiteStore) ClaimDigestRun(ctx context.Context, name string, slot time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO digest_runs (name, slot, status) VALUES (?, ?, 'running')`, name, slot.UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FinishDigestRun stores the outcome of a claimed digest run.
+func (s *SQLiteStore) FinishDigestRun(ctx context.Context, name string, slot time.Time, status, channel, ts string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE digest_runs SET status = ?, channel_id = ?, message_ts = ? WHERE name = ? AND slot = ?`,
+		status, channel, ts, name, slot.UTC().Format(time.RFC3339))
+	return err
+}
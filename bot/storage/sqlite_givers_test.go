@@ -1,4 +1,4 @@
-package main
+package storage
 
 import (
 	"database/sql"
@@ -10,6 +10,7 @@ import (
 )
 
 func TestGetAllGiversAndRecipients(t *testing.T) {
+	ctx := t.Context()
 	// create temp file for sqlite
 	f, err := os.CreateTemp("", "test-beer-*.db")
 	if err != nil {
@@ -25,25 +26,25 @@ func TestGetAllGiversAndRecipients(t *testing.T) {
 	}
 	defer db.Close()
 
-	store, err := NewSQLiteStore(db)
+	store, err := NewSQLiteStore(ctx, db)
 	if err != nil {
 		t.Fatalf("new store: %v", err)
 	}
 
 	// insert some beers
 	now := time.Now()
-	if err := store.AddBeer("giver1", "recipientA", "1000.1", now, 1); err != nil {
+	if err := store.AddBeer(ctx, "giver1", "recipientA", "1000.1", now, 1, BeerNote{}); err != nil {
 		t.Fatalf("addbeer: %v", err)
 	}
-	if err := store.AddBeer("giver2", "recipientA", "1000.2", now, 2); err != nil {
+	if err := store.AddBeer(ctx, "giver2", "recipientA", "1000.2", now, 2, BeerNote{}); err != nil {
 		t.Fatalf("addbeer: %v", err)
 	}
 	// duplicate giver1 to another recipient
-	if err := store.AddBeer("giver1", "recipientB", "1000.3", now, 1); err != nil {
+	if err := store.AddBeer(ctx, "giver1", "recipientB", "1000.3", now, 1, BeerNote{}); err != nil {
 		t.Fatalf("addbeer: %v", err)
 	}
 
-	givers, err := store.GetAllGivers()
+	givers, err := store.GetAllGivers(ctx)
 	if err != nil {
 		t.Fatalf("get all givers: %v", err)
 	}
@@ -56,7 +57,7 @@ func TestGetAllGiversAndRecipients(t *testing.T) {
 		t.Fatalf("unexpected givers list: %v", givers)
 	}
 
-	recipients, err := store.GetAllRecipients()
+	recipients, err := store.GetAllRecipients(ctx)
 	if err != nil {
 		t.Fatalf("get all recipients: %v", err)
 	}
@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLiteStore_IncGet(t *testing.T) {
+	ctx := t.Context()
+	dbPath := "./testdata/test.db"
+	_ = os.Remove(dbPath)
+	if err := os.MkdirAll("./testdata", 0o755); err != nil {
+		t.Fatalf("mkdir testdata: %v", err)
+	}
+	db, err := sql.Open("sqlite", dbPath+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer func() {
+		db.Close()
+		_ = os.Remove(dbPath)
+	}()
+
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	user := "U123"
+	emoji := "beer"
+
+	if c, _ := s.GetCount(ctx, user, emoji); c != 0 {
+		t.Fatalf("expected 0, got %d", c)
+	}
+
+	if err := s.IncEmoji(ctx, user, emoji); err != nil {
+		t.Fatalf("inc1: %v", err)
+	}
+	if c, _ := s.GetCount(ctx, user, emoji); c != 1 {
+		t.Fatalf("expected 1, got %d", c)
+	}
+
+	if err := s.IncEmoji(ctx, user, emoji); err != nil {
+		t.Fatalf("inc2: %v", err)
+	}
+	if c, _ := s.GetCount(ctx, user, emoji); c != 2 {
+		t.Fatalf("expected 2, got %d", c)
+	}
+}
+
+func TestSQLiteStore_Beers(t *testing.T) {
+	ctx := t.Context()
+	dbPath := "./testdata/test_beers.db"
+	_ = os.Remove(dbPath)
+	if err := os.MkdirAll("./testdata", 0o755); err != nil {
+		t.Fatalf("mkdir testdata: %v", err)
+	}
+	db, err := sql.Open("sqlite", dbPath+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer func() { db.Close(); _ = os.Remove(dbPath) }()
+
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	giver := "U1"
+	recv := "U2"
+	now := time.Now().UTC()
+	ts1 := fmt.Sprintf("%d.000000", now.Unix())
+	ts2 := fmt.Sprintf("%d.000000", now.Add(time.Second).Unix())
+
+	// simulate two separate message events each giving 1 beer
+	if err := s.AddBeer(ctx, giver, recv, ts1, now, 1, BeerNote{}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+	if err := s.AddBeer(ctx, giver, recv, ts2, now.Add(time.Second), 1, BeerNote{}); err != nil {
+		t.Fatalf("addbeer2: %v", err)
+	}
+
+	date := now.UTC().Format("2006-01-02")
+	g, err := s.CountGivenOnDate(ctx, giver, date)
+	if err != nil {
+		t.Fatalf("count given: %v", err)
+	}
+	if g != 2 {
+		t.Fatalf("expected 2 given, got %d", g)
+	}
+
+	r, err := s.CountReceived(ctx, recv, "")
+	if err != nil {
+		t.Fatalf("count recv: %v", err)
+	}
+	if r != 2 {
+		t.Fatalf("expected 2 received, got %d", r)
+	}
+}
+
+func TestSQLiteStore_AuditPerRecipientMigration(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// legacy schema: one outcome per event
+	if _, err := db.Exec(`CREATE TABLE beer_events_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id TEXT NOT NULL,
+		giver_id TEXT NOT NULL,
+		recipient_id TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		ts_rfc DATETIME NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(event_id)
+	);`); err != nil {
+		t.Fatalf("create legacy audit: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO beer_events_audit (event_id, giver_id, recipient_id, quantity, status, ts_rfc) VALUES ('ev0', 'U1', 'U2', 1, 'success', '2024-01-01T00:00:00Z')`); err != nil {
+		t.Fatalf("seed legacy audit: %v", err)
+	}
+
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	now := time.Now()
+	if err := s.RecordBeerEventOutcome(ctx, "ev1", "U1", "U2", 1, "success", now); err != nil {
+		t.Fatalf("record outcome: %v", err)
+	}
+	if err := s.RecordBeerEventOutcome(ctx, "ev1", "U1", "U3", 1, "success", now); err != nil {
+		t.Fatalf("record outcome: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM beer_events_audit`).Scan(&n); err != nil {
+		t.Fatalf("count audit: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 audit rows (legacy + 2 recipients), got %d", n)
+	}
+}
+
+func TestSQLiteStore_LocalDayBoundaries(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tz.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	// 00:30 in Berlin on March 11th is still March 10th in UTC
+	local := time.Date(2024, 3, 11, 0, 30, 0, 0, berlin)
+	if err := s.AddBeer(ctx, "U1", "U2", "1710113400.000000", local, 1, BeerNote{}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+
+	if c, _ := s.CountGivenOnDate(ctx, "U1", "2024-03-11"); c != 1 {
+		t.Fatalf("expected gift counted on local day 2024-03-11, got %d", c)
+	}
+	if c, _ := s.CountGivenOnDate(ctx, "U1", "2024-03-10"); c != 0 {
+		t.Fatalf("expected no gift on UTC day 2024-03-10, got %d", c)
+	}
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	if c, _ := s.CountReceivedInDateRange(ctx, "U2", day, day); c != 1 {
+		t.Fatalf("expected 1 received on 2024-03-11, got %d", c)
+	}
+}
+
+func TestSQLiteStore_RecentBeers(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "kudos.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	now := time.Now().UTC()
+	note := BeerNote{Reason: "for the review", ChannelID: "C1", Permalink: "https://example.slack.com/archives/C1/p1"}
+	if err := s.AddBeer(ctx, "U1", "U2", "1.1", now.Add(-time.Hour), 1, note); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+	if err := s.AddBeer(ctx, "U3", "U4", "1.2", now, 2, BeerNote{Reason: "for lunch"}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+
+	all, err := s.RecentBeers(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(all) != 2 || all[0].Reason != "for lunch" || all[1].Permalink != note.Permalink || all[1].ChannelID != "C1" {
+		t.Fatalf("unexpected recent beers %+v", all)
+	}
+	if all[0].Time.IsZero() || all[0].Count != 2 {
+		t.Fatalf("expected time and count on record, got %+v", all[0])
+	}
+
+	mine, err := s.RecentBeers(ctx, "U2", 10)
+	if err != nil {
+		t.Fatalf("recent for user: %v", err)
+	}
+	if len(mine) != 1 || mine[0].Giver != "U1" || mine[0].Reason != "for the review" {
+		t.Fatalf("unexpected recent beers for U2 %+v", mine)
+	}
+	if got, _ := s.RecentReceived(ctx, "U1", 10); len(got) != 0 {
+		t.Fatalf("U1 received nothing, got %+v", got)
+	}
+	if days, err := s.GivingDays(ctx, "U3", 10); err != nil || len(days) != 1 || days[0] != now.Format("2006-01-02") {
+		t.Fatalf("unexpected giving days %v (%v)", days, err)
+	}
+
+	// re-recording the gift replaces its note
+	if err := s.AddBeer(ctx, "U1", "U2", "1.1", now.Add(-time.Hour), 1, BeerNote{Reason: "for the quick review"}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+	if mine, _ = s.RecentBeers(ctx, "U2", 1); len(mine) != 1 || mine[0].Reason != "for the quick review" {
+		t.Fatalf("expected updated reason, got %+v", mine)
+	}
+}
+
+func TestSQLiteStore_RevokeBeer(t *testing.T) {
+	ctx := t.Context()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "undo.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	now := time.Now().UTC()
+	if err := s.AddBeer(ctx, "U1", "U2", "2.1", now.Add(-time.Minute), 1, BeerNote{}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+	if err := s.AddBeer(ctx, "U1", "U3", "2.2", now, 2, BeerNote{}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+	if err := s.SetConfirmation(ctx, "U1", "2.2", "C1", "9.9"); err != nil {
+		t.Fatalf("set confirmation: %v", err)
+	}
+	ref, err := s.LatestGift(ctx, "U1")
+	if err != nil || ref == nil || ref.TS != "2.2" || ref.ConfirmChannel != "C1" || ref.ConfirmTS != "9.9" {
+		t.Fatalf("unexpected latest gift %+v err=%v", ref, err)
+	}
+
+	if err := s.RevokeBeer(ctx, "U1", "U3", "2.2", now); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if c, _ := s.CountGivenInDateRange(ctx, "U1", now, now); c != 1 {
+		t.Fatalf("revoked beers must not count, got %d", c)
+	}
+	if m, _ := s.GetBeersByTS(ctx, "U1", "2.2"); len(m) != 0 {
+		t.Fatalf("revoked beers must not be listed, got %v", m)
+	}
+	if ref, _ = s.LatestGift(ctx, "U1"); ref == nil || ref.TS != "2.1" {
+		t.Fatalf("expected previous gift to become latest, got %+v", ref)
+	}
+	var revokedAt sql.NullString
+	if err := db.QueryRow(`SELECT revoked_at FROM beers WHERE ts = '2.2'`).Scan(&revokedAt); err != nil || !revokedAt.Valid {
+		t.Fatalf("expected row kept with revoked_at, got %v err=%v", revokedAt, err)
+	}
+
+	// giving again clears the undo
+	if err := s.AddBeer(ctx, "U1", "U3", "2.2", now, 2, BeerNote{}); err != nil {
+		t.Fatalf("addbeer: %v", err)
+	}
+	if c, _ := s.CountReceivedInDateRange(ctx, "U3", now, now); c != 2 {
+		t.Fatalf("expected re-given beers to count, got %d", c)
+	}
+}
+
+func TestSQLiteStore_RecordBeerEventOutcome(t *testing.T) {
+	ctx := t.Context()
+	db := openTestDB(t)
+	s, err := NewSQLiteStore(ctx, db)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	now := time.Now()
+	for _, o := range []struct{ event, recipient, status string }{
+		{"E1", "R1", "success"},
+		{"E1", "R1", "duplicate"}, // redelivery: keeps the success
+		{"E2", "R1", "error"},
+		{"E2", "R1", "undone"}, // retry: replaces the error
+	} {
+		if err := s.RecordBeerEventOutcome(ctx, o.event, "G", o.recipient, 1, o.status, now); err != nil {
+			t.Fatalf("record %+v: %v", o, err)
+		}
+	}
+	rows, err := db.Query(`SELECT event_id || ':' || status FROM beer_events_audit ORDER BY event_id`)
+	if err != nil {
+		t.Fatalf("query audit: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, v)
+	}
+	if strings.Join(got, ",") != "E1:success,E2:undone" {
+		t.Fatalf("unexpected outcomes %v", got)
+	}
+}
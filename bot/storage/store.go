@@ -0,0 +1,108 @@
+// Package storage persists beer gifts, dedup state, settings and preferences.
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the storage the bot needs. SQLiteStore, PostgresStore and MemoryStore
+// implement it with the same semantics, checked by the storetest package. Every
+// method stops early with the context's error once ctx is canceled or past its
+// deadline.
+type Store interface {
+	CountGivenInDateRange(ctx context.Context, user string, start, end time.Time) (int, error)
+	CountReceivedInDateRange(ctx context.Context, user string, start, end time.Time) (int, error)
+	CountGivenOnDate(ctx context.Context, user string, date string) (int, error)
+	GetAllGivers(ctx context.Context) ([]string, error)
+	GetAllRecipients(ctx context.Context) ([]string, error)
+	TryMarkEventProcessed(ctx context.Context, eventID string, t time.Time) (bool, error)
+	UnmarkEventProcessed(ctx context.Context, eventID string) error
+	AddBeer(ctx context.Context, giver string, recipient string, ts string, eventTime time.Time, count int, note BeerNote) error
+	GetBeersByTS(ctx context.Context, giver string, ts string) (map[string]int, error)
+	RevokeBeer(ctx context.Context, giver string, recipient string, ts string, at time.Time) error
+	SetConfirmation(ctx context.Context, giver string, ts string, channel, confirmTS string) error
+	LatestGift(ctx context.Context, giver string) (*GiftRef, error)
+	GiftAt(ctx context.Context, giver string, ts string) (*GiftRef, error)
+	RecordBeerEventOutcome(ctx context.Context, eventID, giverID, recipientID string, quantity int, status string, t time.Time) error
+	RecentBeers(ctx context.Context, user string, limit int) ([]BeerRecord, error)
+	RecentReceived(ctx context.Context, recipient string, limit int) ([]BeerRecord, error)
+	GivingDays(ctx context.Context, giver string, limit int) ([]string, error)
+	ReceivedSince(ctx context.Context, recipient string, since time.Time) ([]BeerRecord, error)
+	TopGivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error)
+	TopReceivers(ctx context.Context, start, end time.Time, limit int) ([][2]string, error)
+	UserTotals(ctx context.Context, user string, start, end time.Time) (given int, received int, err error)
+	TotalBeers(ctx context.Context, start, end time.Time) (int, error)
+	Newcomers(ctx context.Context, start, end time.Time) ([]string, error)
+	TopRecipientsOf(ctx context.Context, giver string, limit int) ([][2]string, error)
+	TopGiversTo(ctx context.Context, recipient string, limit int) ([][2]string, error)
+	GetChannelRules(ctx context.Context) ([]ChannelRule, error)
+	AddChannelRule(ctx context.Context, list, entry string) error
+	RemoveChannelRule(ctx context.Context, list, entry string) (bool, error)
+	GetSettings(ctx context.Context) (map[string]string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	RecordAdminAction(ctx context.Context, admin, action, detail string, t time.Time) error
+	AdminActions(ctx context.Context, limit int) ([]AdminAction, error)
+	GetPreferences(ctx context.Context, user string) (Preferences, error)
+	ListPreferences(ctx context.Context) ([]Preferences, error)
+	SetNotify(ctx context.Context, user, notify string) error
+	SetDigestSent(ctx context.Context, user string, t time.Time) error
+	ClaimDigestRun(ctx context.Context, name string, slot time.Time) (bool, error)
+	FinishDigestRun(ctx context.Context, name string, slot time.Time, status, channel, ts string) error
+}
+
+// GrantGiver is the giver recorded for beers granted with /beer-admin grant.
+// It is not a Slack user ID, so grants never use up anyone's daily budget and
+// cannot be undone with /beer-undo; the stores also leave it out of every giver
+// ranking and list.
+const GrantGiver = "beerbot:grant"
+
+// BeerNote is the context stored with a gift: the thanks around the mention and
+// where it was given.
+type BeerNote struct {
+	Reason    string
+	ChannelID string
+	Permalink string
+}
+
+// GiftRef identifies a giver's most recent gift: the beers rows of one message.
+type GiftRef struct {
+	TS             string    // Slack ts the rows are keyed by
+	Time           time.Time // when the gift was given
+	ConfirmChannel string    // confirmation message, empty when there is none
+	ConfirmTS      string
+}
+
+// BeerRecord is one stored gift, as returned by RecentBeers.
+type BeerRecord struct {
+	Giver     string    `json:"giver"`
+	Recipient string    `json:"recipient"`
+	Count     int       `json:"count"`
+	TS        string    `json:"ts"`
+	Time      time.Time `json:"time"`
+	Day       string    `json:"day"`
+	Reason    string    `json:"reason"`
+	ChannelID string    `json:"channel_id"`
+	Permalink string    `json:"permalink"`
+}
+
+// ChannelRule is one entry of the runtime channel allow or deny list.
+type ChannelRule struct {
+	List  string // "allow" or "deny"
+	Entry string // channel ID or channel type
+}
+
+// AdminAction is one entry of the admin audit trail.
+type AdminAction struct {
+	Admin  string
+	Action string
+	Detail string
+	Time   time.Time
+}
+
+// Preferences are a user's personal settings (/beer-settings).
+type Preferences struct {
+	User       string
+	Notify     string    // off|instant|daily; empty uses NOTIFY_DEFAULT
+	DigestSent time.Time // end of the last daily digest, zero if none was sent
+}
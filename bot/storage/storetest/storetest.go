@@ -0,0 +1,282 @@
+// Package storetest checks that a storage.Store implementation behaves like the
+// others: dedup, upserts, inclusive local-day ranges, leaderboard order and ties,
+// grants left out of the giver side, and failing with the context's error once it is canceled.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+)
+
+// Run checks the behaviour every Store backend must share. newStore returns an
+// empty store for each subtest.
+func Run(t *testing.T, newStore func(t *testing.T) storage.Store) {
+	tz := time.FixedZone("UTC+10", 10*60*60)
+	day := func(d int, hour int) time.Time { return time.Date(2024, 3, d, hour, 0, 0, 0, tz) }
+	must := func(t *testing.T, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("event dedup", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		first, err := s.TryMarkEventProcessed(ctx, "Ev1", time.Now())
+		must(t, err)
+		again, err := s.TryMarkEventProcessed(ctx, "Ev1", time.Now())
+		must(t, err)
+		if !first || again {
+			t.Fatalf("expected first mark to win only, got %v then %v", first, again)
+		}
+		must(t, s.UnmarkEventProcessed(ctx, "Ev1"))
+		must(t, s.UnmarkEventProcessed(ctx, "Ev2")) // never marked
+		retried, err := s.TryMarkEventProcessed(ctx, "Ev1", time.Now())
+		must(t, err)
+		if !retried {
+			t.Fatalf("expected an unmarked event to be marked again")
+		}
+	})
+
+	t.Run("add beer upserts per message", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		must(t, s.AddBeer(ctx, "G", "R", "100.1", day(1, 9), 2, storage.BeerNote{Reason: "first"}))
+		must(t, s.AddBeer(ctx, "G", "R", "100.1", day(1, 9), 3, storage.BeerNote{Reason: "edited"}))
+		must(t, s.AddBeer(ctx, "G", "R2", "100.1", day(1, 9), 1, storage.BeerNote{}))
+		got, err := s.GetBeersByTS(ctx, "G", "100.1")
+		must(t, err)
+		if !reflect.DeepEqual(got, map[string]int{"R": 3, "R2": 1}) {
+			t.Fatalf("unexpected gifts by ts: %v", got)
+		}
+		recent, err := s.RecentReceived(ctx, "R", 10)
+		must(t, err)
+		if len(recent) != 1 || recent[0].Reason != "edited" || recent[0].Count != 3 {
+			t.Fatalf("expected a single updated row, got %+v", recent)
+		}
+
+		must(t, s.RevokeBeer(ctx, "G", "R", "100.1", day(1, 10)))
+		if n, _ := s.CountReceivedInDateRange(ctx, "R", day(1, 0), day(1, 0)); n != 0 {
+			t.Fatalf("expected revoked gift not counted, got %d", n)
+		}
+		must(t, s.AddBeer(ctx, "G", "R", "100.1", day(1, 9), 1, storage.BeerNote{}))
+		if n, _ := s.CountReceivedInDateRange(ctx, "R", day(1, 0), day(1, 0)); n != 1 {
+			t.Fatalf("expected re-added gift counted again, got %d", n)
+		}
+		must(t, s.RevokeBeer(ctx, "G", "R2", "100.1", day(1, 10)))
+		if got, _ := s.GetBeersByTS(ctx, "G", "100.1"); !reflect.DeepEqual(got, map[string]int{"R": 1}) {
+			t.Fatalf("unexpected gifts after revoke: %v", got)
+		}
+	})
+
+	t.Run("date ranges are inclusive local days", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		// 01:00 on the 2nd in UTC+10 is still the 1st in UTC; the giver's day counts
+		must(t, s.AddBeer(ctx, "G", "R", "1.1", day(2, 1), 1, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "G", "R", "2.1", day(4, 23), 2, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "G", "R", "3.1", day(5, 12), 4, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "G", "R", "4.1", day(1, 12), 8, storage.BeerNote{}))
+		checks := []struct {
+			name string
+			got  func() (int, error)
+			want int
+		}{
+			{"given 2nd-4th", func() (int, error) { return s.CountGivenInDateRange(ctx, "G", day(2, 0), day(4, 0)) }, 3},
+			{"received 2nd-4th", func() (int, error) { return s.CountReceivedInDateRange(ctx, "R", day(2, 0), day(4, 0)) }, 3},
+			{"given on 2nd", func() (int, error) { return s.CountGivenOnDate(ctx, "G", "2024-03-02") }, 1},
+			{"total 1st-5th", func() (int, error) { return s.TotalBeers(ctx, day(1, 0), day(5, 0)) }, 15},
+		}
+		for _, c := range checks {
+			got, err := c.got()
+			must(t, err)
+			if got != c.want {
+				t.Errorf("%s: got %d, want %d", c.name, got, c.want)
+			}
+		}
+		given, received, err := s.UserTotals(ctx, "G", day(5, 0), day(5, 0))
+		must(t, err)
+		if given != 4 || received != 0 {
+			t.Fatalf("unexpected user totals %d/%d", given, received)
+		}
+		days, err := s.GivingDays(ctx, "G", 3)
+		must(t, err)
+		if !reflect.DeepEqual(days, []string{"2024-03-05", "2024-03-04", "2024-03-02"}) {
+			t.Fatalf("unexpected giving days %v", days)
+		}
+	})
+
+	t.Run("leaderboards order by total then user", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		must(t, s.AddBeer(ctx, "B", "X", "1.1", day(1, 9), 3, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "A", "X", "1.2", day(1, 9), 2, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "A", "Y", "1.3", day(1, 9), 1, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "C", "Y", "1.4", day(1, 9), 1, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, storage.GrantGiver, "Y", "1.5", day(1, 9), 1, storage.BeerNote{}))
+		givers, err := s.TopGivers(ctx, day(1, 0), day(1, 0), 10)
+		must(t, err)
+		if want := [][2]string{{"A", "3"}, {"B", "3"}, {"C", "1"}}; !reflect.DeepEqual(givers, want) {
+			t.Fatalf("top givers: got %v, want %v", givers, want)
+		}
+		if top, _ := s.TopGivers(ctx, day(1, 0), day(1, 0), 2); len(top) != 2 {
+			t.Fatalf("expected limit applied, got %v", top)
+		}
+		receivers, err := s.TopReceivers(ctx, day(1, 0), day(1, 0), 10)
+		must(t, err)
+		if want := [][2]string{{"X", "5"}, {"Y", "3"}}; !reflect.DeepEqual(receivers, want) {
+			t.Fatalf("top receivers: got %v, want %v", receivers, want)
+		}
+		partners, err := s.TopRecipientsOf(ctx, "A", 5)
+		must(t, err)
+		if want := [][2]string{{"X", "2"}, {"Y", "1"}}; !reflect.DeepEqual(partners, want) {
+			t.Fatalf("top recipients of A: got %v, want %v", partners, want)
+		}
+		partners, err = s.TopGiversTo(ctx, "Y", 5)
+		must(t, err)
+		if want := [][2]string{{"A", "1"}, {"C", "1"}}; !reflect.DeepEqual(partners, want) {
+			t.Fatalf("top givers to Y: got %v, want %v", partners, want)
+		}
+		newcomers, err := s.Newcomers(ctx, day(1, 0), day(1, 0))
+		must(t, err)
+		if want := []string{"A", "B", "C", "X", "Y"}; !reflect.DeepEqual(newcomers, want) {
+			t.Fatalf("newcomers: got %v, want %v", newcomers, want)
+		}
+		givers2, _ := s.GetAllGivers(ctx)
+		recipients, _ := s.GetAllRecipients(ctx)
+		slices.Sort(givers2)
+		slices.Sort(recipients)
+		if !reflect.DeepEqual(givers2, []string{"A", "B", "C"}) || !reflect.DeepEqual(recipients, []string{"X", "Y"}) {
+			t.Fatalf("unexpected givers %v / recipients %v", givers2, recipients)
+		}
+	})
+
+	t.Run("latest gift and recent beers", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		if ref, err := s.LatestGift(ctx, "G"); err != nil || ref != nil {
+			t.Fatalf("expected no gift, got %+v %v", ref, err)
+		}
+		must(t, s.AddBeer(ctx, "G", "R", "1.1", day(1, 9), 1, storage.BeerNote{}))
+		must(t, s.AddBeer(ctx, "G", "R", "2.1", day(2, 9), 1, storage.BeerNote{}))
+		must(t, s.SetConfirmation(ctx, "G", "2.1", "C1", "9.9"))
+		ref, err := s.LatestGift(ctx, "G")
+		must(t, err)
+		if ref == nil || ref.TS != "2.1" || ref.ConfirmChannel != "C1" || ref.ConfirmTS != "9.9" || !ref.Time.Equal(day(2, 9)) {
+			t.Fatalf("unexpected latest gift %+v", ref)
+		}
+		if at, err := s.GiftAt(ctx, "G", "2.1"); err != nil || !reflect.DeepEqual(at, ref) {
+			t.Fatalf("gift at 2.1: got %+v %v, want %+v", at, err, ref)
+		}
+		must(t, s.RevokeBeer(ctx, "G", "R", "2.1", day(2, 10)))
+		if ref, _ := s.LatestGift(ctx, "G"); ref == nil || ref.TS != "1.1" {
+			t.Fatalf("expected undone gift skipped, got %+v", ref)
+		}
+		if at, err := s.GiftAt(ctx, "G", "2.1"); err != nil || at != nil {
+			t.Fatalf("expected no active gift at 2.1, got %+v %v", at, err)
+		}
+		must(t, s.AddBeer(ctx, "O", "G", "3.1", day(3, 9), 2, storage.BeerNote{}))
+		recent, err := s.RecentBeers(ctx, "G", 10)
+		must(t, err)
+		if len(recent) != 2 || recent[0].TS != "3.1" || recent[1].TS != "1.1" || recent[1].Day != "2024-03-01" {
+			t.Fatalf("unexpected recent beers %+v", recent)
+		}
+		since, err := s.ReceivedSince(ctx, "R", day(1, 0))
+		must(t, err)
+		if len(since) != 1 || since[0].TS != "1.1" {
+			t.Fatalf("unexpected received since %+v", since)
+		}
+	})
+
+	t.Run("rules, settings and audit", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		must(t, s.AddChannelRule(ctx, "deny", "C1"))
+		must(t, s.AddChannelRule(ctx, "deny", "C1"))
+		must(t, s.AddChannelRule(ctx, "allow", "public"))
+		rules, err := s.GetChannelRules(ctx)
+		must(t, err)
+		if want := []storage.ChannelRule{{List: "allow", Entry: "public"}, {List: "deny", Entry: "C1"}}; !reflect.DeepEqual(rules, want) {
+			t.Fatalf("rules: got %v, want %v", rules, want)
+		}
+		if ok, _ := s.RemoveChannelRule(ctx, "deny", "C1"); !ok {
+			t.Fatalf("expected rule removed")
+		}
+		if ok, _ := s.RemoveChannelRule(ctx, "deny", "C1"); ok {
+			t.Fatalf("expected second remove to report missing")
+		}
+
+		must(t, s.SetSetting(ctx, "max_gift", "3"))
+		must(t, s.SetSetting(ctx, "max_gift", "4"))
+		settings, err := s.GetSettings(ctx)
+		must(t, err)
+		if !reflect.DeepEqual(settings, map[string]string{"max_gift": "4"}) {
+			t.Fatalf("unexpected settings %v", settings)
+		}
+
+		must(t, s.RecordAdminAction(ctx, "A", "readonly", "on", day(1, 9)))
+		must(t, s.RecordAdminAction(ctx, "A", "readonly", "off", day(1, 10)))
+		actions, err := s.AdminActions(ctx, 1)
+		must(t, err)
+		if len(actions) != 1 || actions[0].Detail != "off" || !actions[0].Time.Equal(day(1, 10)) {
+			t.Fatalf("unexpected admin actions %+v", actions)
+		}
+	})
+
+	t.Run("preferences and digest runs", func(t *testing.T) {
+		ctx := t.Context()
+		s := newStore(t)
+		p, err := s.GetPreferences(ctx, "U")
+		must(t, err)
+		if p.User != "U" || p.Notify != "" || !p.DigestSent.IsZero() {
+			t.Fatalf("expected empty preferences, got %+v", p)
+		}
+		must(t, s.SetNotify(ctx, "U", "daily"))
+		must(t, s.SetDigestSent(ctx, "U", day(1, 17)))
+		must(t, s.SetDigestSent(ctx, "V", day(2, 17)))
+		p, _ = s.GetPreferences(ctx, "U")
+		if p.Notify != "daily" || !p.DigestSent.Equal(day(1, 17)) {
+			t.Fatalf("unexpected preferences %+v", p)
+		}
+		all, err := s.ListPreferences(ctx)
+		must(t, err)
+		if len(all) != 2 || all[0].User != "U" || all[1].User != "V" || all[1].Notify != "" {
+			t.Fatalf("unexpected preference list %+v", all)
+		}
+
+		slot := day(1, 9)
+		claimed, err := s.ClaimDigestRun(ctx, "digest:C1", slot)
+		must(t, err)
+		again, err := s.ClaimDigestRun(ctx, "digest:C1", slot)
+		must(t, err)
+		if !claimed || again {
+			t.Fatalf("expected one claim per slot, got %v then %v", claimed, again)
+		}
+		must(t, s.FinishDigestRun(ctx, "digest:C1", slot, "posted", "C1", "1.1"))
+		if other, _ := s.ClaimDigestRun(ctx, "digest:C2", slot); !other {
+			t.Fatalf("expected claims per digest name")
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		s := newStore(t)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if err := s.AddBeer(ctx, "G", "R", "1.1", day(1, 9), 1, storage.BeerNote{}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled from a write, got %v", err)
+		}
+		if _, err := s.TopGivers(ctx, day(1, 0), day(1, 0), 5); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled from a query, got %v", err)
+		}
+		if n, _ := s.TotalBeers(t.Context(), day(1, 0), day(1, 0)); n != 0 {
+			t.Fatalf("canceled write must not be stored, got %d beers", n)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// undoActionID is the action ID of the Undo button on gift confirmations. Its value
+// is "<giver>|<gift ts>".
+const undoActionID = "beer_undo"
+
+// defaultUndoWindow is how long a gift can be undone unless UNDO_WINDOW says otherwise.
+const defaultUndoWindow = 15 * time.Minute
+
+// confirmationBlocks renders a gift confirmation with an Undo button (when undo is enabled).
+func (bot *MinimalSlackBot) confirmationBlocks(message, giver, giftTS string) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, message, false, false), nil, nil),
+	}
+	if bot.undoWindow > 0 {
+		button := slack.NewButtonBlockElement(undoActionID, giver+"|"+giftTS, slack.NewTextBlockObject(slack.PlainTextType, "Undo", false, false))
+		blocks = append(blocks, slack.NewActionBlock("", button))
+	}
+	return blocks
+}
+
+// handleUndoAction handles a click on a confirmation's Undo button. Only the giver may undo.
+func (bot *MinimalSlackBot) handleUndoAction(ctx context.Context, cb slack.InteractionCallback, action *slack.BlockAction) {
+	giver, ts, ok := strings.Cut(action.Value, "|")
+	if !ok {
+		return
+	}
+	if cb.User.ID != giver {
+		bot.postEphemeral(cb.Channel.ID, cb.User.ID, "⛔ Only the giver can undo this gift.")
+		return
+	}
+	reply := bot.undoGift(ctx, giver, ts, parseSlackTS(ts), cb.Channel.ID, cb.Message.Timestamp)
+	bot.postEphemeral(cb.Channel.ID, giver, reply)
+}
+
+// handleBeerUndo implements /beer-undo: it reverses the caller's most recent gift.
+func (bot *MinimalSlackBot) handleBeerUndo(ctx context.Context, cmd slack.SlashCommand) {
+	ref, err := bot.store.LatestGift(ctx, cmd.UserID)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", cmd.UserID).Msg("Failed to look up latest gift")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		bot.postEphemeral(cmd.ChannelID, cmd.UserID, "⚠️ Could not look up your last gift, please try again.")
+		return
+	}
+	if ref == nil {
+		bot.postEphemeral(cmd.ChannelID, cmd.UserID, "🤷 You have no gift to undo.")
+		return
+	}
+	bot.postEphemeral(cmd.ChannelID, cmd.UserID, bot.undoGift(ctx, cmd.UserID, ref.TS, ref.Time, ref.ConfirmChannel, ref.ConfirmTS))
+}
+
+// undoGift revokes every beer of the giver's gift stored under ts, records an
+// "undone" outcome per recipient and updates the confirmation message in place.
+// givenAt is when the gift was made; gifts older than UNDO_WINDOW are kept.
+// It returns the reply for the giver.
+func (bot *MinimalSlackBot) undoGift(ctx context.Context, giver, ts string, givenAt time.Time, confirmChannel, confirmTS string) string {
+	if bot.undoWindow <= 0 {
+		return "Undo is disabled."
+	}
+	if time.Since(givenAt) > bot.undoWindow {
+		return fmt.Sprintf("⌛ Gifts can only be undone within %s.", bot.undoWindow)
+	}
+	counts, err := bot.store.GetBeersByTS(ctx, giver, ts)
+	if err != nil {
+		bot.logger.Error().Err(err).Str("giver", giver).Str("ts", ts).Msg("Failed to load gift to undo")
+		bot.errorCounter.WithLabelValues("storage_error").Inc()
+		return "⚠️ Could not undo the gift, please try again."
+	}
+	if len(counts) == 0 {
+		return "🤷 This gift was already undone."
+	}
+	if bot.readOnly {
+		bot.logger.Info().Str("mode", "read-only").Msg("Skipping undo (READ_ONLY enabled)")
+		return "Read-only mode: nothing was changed."
+	}
+
+	recipients := make([]string, 0, len(counts))
+	for r := range counts {
+		recipients = append(recipients, r)
+	}
+	sort.Strings(recipients)
+
+	now := time.Now()
+	eventID := "undo:" + giver + ":" + ts
+	var undone []recipientGift
+	for _, recipient := range recipients {
+		if err := bot.store.RevokeBeer(ctx, giver, recipient, ts, now); err != nil {
+			_ = bot.store.RecordBeerEventOutcome(ctx, eventID, giver, recipient, counts[recipient], "error", now)
+			bot.logger.Error().
+				Err(err).
+				Str("giver", giver).
+				Str("recipient", recipient).
+				Str("ts", ts).
+				Msg("Failed to undo beer")
+			bot.errorCounter.WithLabelValues("storage_error").Inc()
+			continue
+		}
+		_ = bot.store.RecordBeerEventOutcome(ctx, eventID, giver, recipient, counts[recipient], "undone", now)
+		bot.eventCounter.WithLabelValues("beer_giving", "undone").Inc()
+		undone = append(undone, recipientGift{recipient: recipient, quantity: counts[recipient]})
+	}
+	if len(undone) == 0 {
+		return "⚠️ Could not undo the gift, please try again."
+	}
+	bot.refreshHomes(ctx, append([]string{giver}, recipients...)...)
+	bot.strikeConfirmation(confirmChannel, confirmTS, formatBeerConfirmation(giver, undone), fmt.Sprintf("↩️ Undone by <@%s>", giver))
+	return "↩️ Undone: " + formatGiftList(undone) + "."
+}
+
+// strikeConfirmation rewrites a gift confirmation in place: the gift struck
+// through, followed by why. It also drops the Undo button. Nothing happens when
+// the gift has no confirmation.
+func (bot *MinimalSlackBot) strikeConfirmation(channel, ts, gift, note string) {
+	if channel == "" || ts == "" {
+		return
+	}
+	text := fmt.Sprintf("~%s~\n%s", gift, note)
+	_, _, _, err := bot.api.UpdateMessage(channel, ts,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)),
+	)
+	if err != nil {
+		bot.logger.Warn().Err(err).Str("channel", channel).Str("ts", ts).Msg("Failed to update confirmation message")
+	}
+}
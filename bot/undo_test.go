@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielWeeber/beer-with-me/bot/storage"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestUndoGift(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, ms, func(b *MinimalSlackBot) { b.undoWindow = time.Minute })
+
+	ts := formatSlackTS(time.Now())
+	ev := &slackevents.MessageEvent{Text: "<@UA> 🍺🍺", User: "UG", Channel: "C1", EventTimeStamp: ts}
+	bot.processBeerGiving(ctx, ev, "env-undo", bot.parseGift(ev.Text))
+	gift, _ := ms.LatestGift(ctx, "UG")
+	if received(t, ms)["UA"] != 2 || gift == nil || gift.ConfirmTS == "" {
+		t.Fatalf("expected stored gift with confirmation, got beers=%v gift=%+v", received(t, ms), gift)
+	}
+	blocks := bot.confirmationBlocks("🍻 <@UG> gave 2 beers to <@UA>!", "UG", ts)
+	button := blocks[1].(*slack.ActionBlock).Elements.ElementSet[0].(*slack.ButtonBlockElement)
+	if button.ActionID != undoActionID || button.Value != "UG|"+ts {
+		t.Fatalf("unexpected undo button %+v", button)
+	}
+
+	click := func(user string) {
+		var cb slack.InteractionCallback
+		cb.Type = slack.InteractionTypeBlockActions
+		cb.User.ID = user
+		cb.Channel.ID = "C1"
+		cb.Message.Timestamp = gift.ConfirmTS
+		cb.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: undoActionID, Value: button.Value}}
+		_, followUp := bot.handleInteraction(ctx, cb)
+		if followUp == nil {
+			t.Fatalf("expected undo follow-up")
+		}
+		followUp()
+	}
+
+	// only the giver can undo
+	click("UOTHER")
+	if beers := received(t, ms); beers["UA"] != 2 {
+		t.Fatalf("undo by another user must not revoke, got %v", beers)
+	}
+	click("UG")
+	if beers := received(t, ms); len(beers) != 0 {
+		t.Fatalf("expected gift revoked, got %v", beers)
+	}
+	if got := statuses(ms); got[len(got)-1] != "undone" {
+		t.Fatalf("expected undone outcome, got %v", got)
+	}
+	if reply := bot.undoGift(ctx, "UG", ts, time.Now(), "", ""); !strings.Contains(reply, "already undone") {
+		t.Fatalf("expected already undone reply, got %q", reply)
+	}
+
+	// outside the window the gift stays
+	old := time.Now().Add(-2 * time.Minute)
+	_ = ms.AddBeer(ctx, "UG", "UB", "1717691574.000100", old, 1, storage.BeerNote{})
+	if reply := bot.undoGift(ctx, "UG", "1717691574.000100", old, "", ""); !strings.Contains(reply, "within") || received(t, ms)["UB"] != 1 {
+		t.Fatalf("expected window rejection, got %q beers=%v", reply, received(t, ms))
+	}
+}
+
+// failingRevokes fails the first n RevokeBeer calls.
+type failingRevokes struct {
+	storage.Store
+	n int
+}
+
+func (s *failingRevokes) RevokeBeer(ctx context.Context, giver, recipient, ts string, at time.Time) error {
+	if s.n > 0 {
+		s.n--
+		return errors.New("database is locked")
+	}
+	return s.Store.RevokeBeer(ctx, giver, recipient, ts, at)
+}
+
+func TestUndoGift_RetryAfterError(t *testing.T) {
+	ctx := t.Context()
+	ms := storage.NewMemoryStore()
+	bot := newTestBot(t, &failingRevokes{Store: ms, n: 1}, func(b *MinimalSlackBot) { b.undoWindow = time.Minute })
+
+	ts := formatSlackTS(time.Now())
+	_ = ms.AddBeer(ctx, "UG", "UA", ts, time.Now(), 2, storage.BeerNote{})
+	if reply := bot.undoGift(ctx, "UG", ts, time.Now(), "", ""); !strings.Contains(reply, "try again") {
+		t.Fatalf("expected failed undo, got %q", reply)
+	}
+	if reply := bot.undoGift(ctx, "UG", ts, time.Now(), "", ""); strings.Contains(reply, "try again") || received(t, ms)["UA"] != 0 {
+		t.Fatalf("expected retry to undo the gift, got %q beers=%v", reply, received(t, ms))
+	}
+	// the retry's outcome replaces the failed attempt's
+	if got := statuses(ms); len(got) != 1 || got[0] != "undone" {
+		t.Fatalf("expected a single undone outcome, got %v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// giftVocabulary is the set of words and emoji that express a beer gift. It is
+// compiled once at startup into the lookup tables used by the gift tokenizer.
+type giftVocabulary struct {
+	emoji    map[string]int // unicode emoji or :shortcode: -> beers per occurrence
+	keywords []string       // words that mean beer ("beer", "beers")
+	verbs    []string       // giving verbs ("give", "gift", "gebe", ...)
+
+	emojiPrefix *regexp.Regexp  // a vocabulary emoji at the start of the input
+	keywordSet  map[string]bool // lower-case keywords
+	verbSet     map[string]bool // lower-case verbs
+}
+
+var (
+	defaultGiftEmoji    = "🍺,🍻,:beer:,:beers:"
+	defaultGiftKeywords = "beer,beers"
+	defaultGiftVerbs    = "give,gives,giving,gift,gifting"
+)
+
+// defaultVocabulary reproduces the built-in gift syntax; it is used when the bot
+// has no configured vocabulary (e.g. in tests).
+var defaultVocabulary = mustVocabulary(defaultGiftEmoji, defaultGiftKeywords, defaultGiftVerbs)
+
+func mustVocabulary(emoji, keywords, verbs string) *giftVocabulary {
+	v, err := newGiftVocabulary(emoji, keywords, verbs)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// newGiftVocabulary parses comma separated lists and compiles the matcher.
+// Emoji entries may carry a weight ("🍻=2", ":prost:=1"); the default weight is 1.
+func newGiftVocabulary(emoji, keywords, verbs string) (*giftVocabulary, error) {
+	v := &giftVocabulary{emoji: map[string]int{}}
+	for _, item := range splitList(emoji) {
+		name, weight := item, 1
+		if i := strings.LastIndex(item, "="); i > 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid weight in gift emoji %q", item)
+			}
+			name, weight = strings.TrimSpace(item[:i]), n
+		}
+		v.emoji[name] = weight
+	}
+	v.keywords = splitList(strings.ToLower(keywords))
+	v.verbs = splitList(strings.ToLower(verbs))
+	if len(v.emoji) == 0 && len(v.keywords) == 0 {
+		return nil, fmt.Errorf("gift vocabulary needs at least one emoji or keyword")
+	}
+	v.compile()
+	return v, nil
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// alternation quotes items into a regex alternation, longest first so that
+// overlapping entries prefer the longer match.
+func alternation(items []string) string {
+	sorted := append([]string(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	quoted := make([]string, len(sorted))
+	for i, it := range sorted {
+		quoted[i] = regexp.QuoteMeta(it)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// compile builds the tokenizer tables. Emoji are matched with an anchored
+// alternation (longest first, case-insensitive for shortcodes); keywords and
+// verbs are whole words looked up in sets.
+func (v *giftVocabulary) compile() {
+	var emojis []string
+	for e := range v.emoji {
+		emojis = append(emojis, e)
+	}
+	v.emojiPrefix = nil
+	if len(emojis) > 0 {
+		v.emojiPrefix = regexp.MustCompile(`(?i)^(?:` + alternation(emojis) + `)`)
+	}
+	v.keywordSet = map[string]bool{}
+	for _, k := range v.keywords {
+		v.keywordSet[k] = true
+	}
+	v.verbSet = map[string]bool{}
+	for _, w := range v.verbs {
+		v.verbSet[w] = true
+	}
+}
+
+// emojiWeight returns the weight of a matched emoji (case-insensitive for shortcodes).
+func (v *giftVocabulary) emojiWeight(e string) int {
+	if w, ok := v.emoji[e]; ok {
+		return w
+	}
+	for name, w := range v.emoji {
+		if strings.EqualFold(name, e) {
+			return w
+		}
+	}
+	return 0
+}
+
+// vocabulary returns the configured gift vocabulary or the built-in default.
+func (bot *MinimalSlackBot) vocabulary() *giftVocabulary {
+	if bot.vocab == nil {
+		return defaultVocabulary
+	}
+	return bot.vocab
+}